@@ -4,12 +4,17 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 )
 
 const (
 	Name = "aesgcm256"
 )
 
+var (
+	errCiphertextTooShort = errors.New("aesgcm256: ciphertext too short")
+)
+
 type aesGcm256 struct{}
 
 func New() *aesGcm256 {
@@ -17,6 +22,10 @@ func New() *aesGcm256 {
 }
 
 func (c *aesGcm256) Encrypt(key []byte, data []byte) ([]byte, error) {
+	return c.EncryptWithAAD(key, data, nil)
+}
+
+func (c *aesGcm256) EncryptWithAAD(key []byte, data []byte, aad []byte) ([]byte, error) {
 	aes, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -32,12 +41,16 @@ func (c *aesGcm256) Encrypt(key []byte, data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	ciphertext := block.Seal(nil, nonce, data, nil)
+	ciphertext := block.Seal(nil, nonce, data, aad)
 	ciphertext = append(ciphertext, nonce...)
 	return ciphertext, nil
 }
 
 func (c *aesGcm256) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	return c.DecryptWithAAD(key, ciphertext, nil)
+}
+
+func (c *aesGcm256) DecryptWithAAD(key []byte, ciphertext []byte, aad []byte) ([]byte, error) {
 	aes, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -46,8 +59,11 @@ func (c *aesGcm256) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(ciphertext) < block.NonceSize() {
+		return nil, errCiphertextTooShort
+	}
 	nonce := ciphertext[len(ciphertext)-block.NonceSize():]
-	plaintext, err := block.Open(nil, nonce, ciphertext[:len(ciphertext)-block.NonceSize()], nil)
+	plaintext, err := block.Open(nil, nonce, ciphertext[:len(ciphertext)-block.NonceSize()], aad)
 	return plaintext, err
 }
 
@@ -9,6 +9,15 @@ var (
 	registry = make(map[string]Algorithm)
 )
 
+// UnsupportedAlgorithmError is returned by Get if no algorithm is registered with the name.
+type UnsupportedAlgorithmError struct {
+	Name string
+}
+
+func (e *UnsupportedAlgorithmError) Error() string {
+	return fmt.Sprintf("algorithm %v not registered", e.Name)
+}
+
 // Algorithm implementations encrypt and decrypt data.
 type Algorithm interface {
 	Encrypt(key []byte, data []byte) ([]byte, error)
@@ -16,6 +25,14 @@ type Algorithm interface {
 	NeedsKey() bool
 }
 
+// AuthenticatedAlgorithm implementations can bind associated data to a ciphertext. Decryption
+// fails unless the same associated data is provided.
+type AuthenticatedAlgorithm interface {
+	Algorithm
+	EncryptWithAAD(key []byte, data []byte, aad []byte) ([]byte, error)
+	DecryptWithAAD(key []byte, ciphertext []byte, aad []byte) ([]byte, error)
+}
+
 // Register adds a value to the store of all algorithms
 func Register(name string, a Algorithm) error {
 	_, ok := registry[name]
@@ -40,7 +57,7 @@ func GetRegisteredAlgorithmsNames() []string {
 func Get(name string) (Algorithm, error) {
 	algo, ok := registry[name]
 	if !ok {
-		return nil, fmt.Errorf("algorithm %v not registered", name)
+		return nil, &UnsupportedAlgorithmError{Name: name}
 	}
 	return algo, nil
 }
@@ -3,6 +3,7 @@ package algorithms_test
 import (
 	"bytes"
 	"crypto/rand"
+	"errors"
 	"strings"
 	"testing"
 
@@ -58,3 +59,35 @@ func TestAlgorithms(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthenticatedAlgorithms(t *testing.T) {
+	var key [32]byte
+	_, err := rand.Read(key[:])
+	assert.NoError(t, err)
+
+	for _, algo := range []algorithms.AuthenticatedAlgorithm{secretbox.New(), aesgcm256.New()} {
+		ciphertext, err := algo.EncryptWithAAD(key[:], []byte("launch codes"), []byte("name"))
+		assert.NoError(t, err)
+
+		plaintext, err := algo.DecryptWithAAD(key[:], ciphertext, []byte("name"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("launch codes"), plaintext)
+
+		// decrypting with different or missing associated data fails
+		_, err = algo.DecryptWithAAD(key[:], ciphertext, []byte("other"))
+		assert.Error(t, err)
+		_, err = algo.Decrypt(key[:], ciphertext)
+		assert.Error(t, err)
+
+		// truncated ciphertexts fail without panicking
+		_, err = algo.DecryptWithAAD(key[:], ciphertext[:4], []byte("name"))
+		assert.Error(t, err)
+	}
+}
+
+func TestGet_unsupported(t *testing.T) {
+	_, err := algorithms.Get("rot13")
+	var unsupported *algorithms.UnsupportedAlgorithmError
+	assert.True(t, errors.As(err, &unsupported))
+	assert.Equal(t, "rot13", unsupported.Name)
+}
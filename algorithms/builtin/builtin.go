@@ -0,0 +1,31 @@
+// Package builtin registers the algorithms that are distributed with biscuit.
+package builtin
+
+import (
+	"sync"
+
+	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/algorithms/aesgcm256"
+	"github.com/dcoker/biscuit/algorithms/plain"
+	"github.com/dcoker/biscuit/algorithms/secretbox"
+)
+
+var (
+	once        sync.Once
+	registerErr error
+)
+
+// Register adds secretbox, aesgcm256, and none to the algorithms registry. It is safe to call
+// more than once.
+func Register() error {
+	once.Do(func() {
+		if registerErr = algorithms.Register(secretbox.Name, secretbox.New()); registerErr != nil {
+			return
+		}
+		if registerErr = algorithms.Register(plain.Name, plain.New()); registerErr != nil {
+			return
+		}
+		registerErr = algorithms.Register(aesgcm256.Name, aesgcm256.New())
+	})
+	return registerErr
+}
@@ -2,9 +2,12 @@ package secretbox
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"io"
 
 	"errors"
 
+	"golang.org/x/crypto/hkdf"
 	"golang.org/x/crypto/nacl/secretbox"
 )
 
@@ -13,7 +16,8 @@ const (
 )
 
 var (
-	errUnableToDecrypt = errors.New("secretbox: unable to decrypt")
+	errUnableToDecrypt    = errors.New("secretbox: unable to decrypt")
+	errCiphertextTooShort = errors.New("secretbox: ciphertext too short")
 )
 
 type secretBox struct{}
@@ -23,22 +27,40 @@ func New() *secretBox {
 }
 
 func (s *secretBox) Encrypt(key []byte, data []byte) ([]byte, error) {
+	return s.EncryptWithAAD(key, data, nil)
+}
+
+// EncryptWithAAD encrypts data under a key derived from key and aad. Secretbox has no native
+// support for associated data, so binding it into the key ensures decryption fails unless the
+// same aad is provided.
+func (s *secretBox) EncryptWithAAD(key []byte, data []byte, aad []byte) ([]byte, error) {
 	var nonce [24]byte
 	if _, err := rand.Read(nonce[:]); err != nil {
 		return nil, err
 	}
-	var keyArr [32]byte
-	copy(keyArr[:], key)
-	return secretbox.Seal(nonce[:], data, &nonce, &keyArr), nil
+	keyArr, err := deriveKey(key, aad)
+	if err != nil {
+		return nil, err
+	}
+	return secretbox.Seal(nonce[:], data, &nonce, keyArr), nil
 }
 
 func (s *secretBox) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	return s.DecryptWithAAD(key, ciphertext, nil)
+}
+
+func (s *secretBox) DecryptWithAAD(key []byte, ciphertext []byte, aad []byte) ([]byte, error) {
+	if len(ciphertext) < 24 {
+		return nil, errCiphertextTooShort
+	}
 	var nonce [24]byte
 	copy(nonce[:], ciphertext[:24])
-	var keyArr [32]byte
-	copy(keyArr[:], key)
+	keyArr, err := deriveKey(key, aad)
+	if err != nil {
+		return nil, err
+	}
 	var out []byte
-	out, ok := secretbox.Open(out[:0], ciphertext[24:], &nonce, &keyArr)
+	out, ok := secretbox.Open(out[:0], ciphertext[24:], &nonce, keyArr)
 	if !ok {
 		return nil, errUnableToDecrypt
 	}
@@ -48,3 +70,17 @@ func (s *secretBox) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
 func (s *secretBox) NeedsKey() bool {
 	return true
 }
+
+// deriveKey returns key unchanged if aad is empty, otherwise an HKDF-SHA256 subkey of key with
+// aad as the info parameter.
+func deriveKey(key []byte, aad []byte) (*[32]byte, error) {
+	var keyArr [32]byte
+	if len(aad) == 0 {
+		copy(keyArr[:], key)
+		return &keyArr, nil
+	}
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, aad), keyArr[:]); err != nil {
+		return nil, err
+	}
+	return &keyArr, nil
+}
@@ -0,0 +1,195 @@
+// Package biscuit reads and writes secrets in biscuit stores from Go programs.
+//
+//	client, err := biscuit.Open("secrets.yml", biscuit.WithRegionPriority("us-west-2"))
+//	if err != nil {
+//		return err
+//	}
+//	password, err := client.Get(ctx, "db_password")
+//
+// The algorithms distributed with biscuit are registered when the package is loaded. Key
+// managers are looked up by label, first among those passed to WithKeyManager and then among the
+// built-in key managers (kms, age, and passphrase).
+package biscuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/dcoker/biscuit/algorithms/builtin"
+	"github.com/dcoker/biscuit/internal/envelope"
+	"github.com/dcoker/biscuit/internal/identity"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+)
+
+func init() {
+	if err := builtin.Register(); err != nil {
+		panic(err)
+	}
+}
+
+// Client reads and writes the secrets in a store.
+type Client struct {
+	store          store.Store
+	regionPriority []string
+	keyManagers    map[string]keymanager.KeyManager
+	lockTimeout    time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithRegionPriority sets the AWS regions to try first, in order, when decrypting values that
+// are encrypted under KMS keys in several regions.
+func WithRegionPriority(regions ...string) Option {
+	return func(c *Client) {
+		c.regionPriority = regions
+	}
+}
+
+// WithKeyManager uses keyManager for values whose key manager is keyManager.Label(), instead of
+// the built-in key manager with that label, if any.
+func WithKeyManager(keyManager keymanager.KeyManager) Option {
+	return func(c *Client) {
+		c.keyManagers[keyManager.Label()] = keyManager
+	}
+}
+
+// WithLockTimeout sets how long Put waits for other writers to release the store. See
+// store.LockTimeout.
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.lockTimeout = timeout
+	}
+}
+
+// Open returns a Client for the store at location, which is a file name or a URL accepted by
+// store.Open.
+func Open(location string, opts ...Option) (*Client, error) {
+	c := &Client{keyManagers: make(map[string]keymanager.KeyManager)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	var storeOpts []store.Option
+	if c.lockTimeout > 0 {
+		storeOpts = append(storeOpts, store.LockTimeout(c.lockTimeout))
+	}
+	s, err := store.Open(location, storeOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c.store = s
+	return c, nil
+}
+
+// Get returns the plaintext of the secret called name. It returns a *store.NameNotFoundError,
+// which matches ErrNameNotFound, if there is no such secret, or an *UndecryptableError if none of its values
+// could be decrypted.
+func (c *Client) Get(ctx context.Context, name string) ([]byte, error) {
+	values, err := c.store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.decrypt(ctx, name, values)
+}
+
+// GetAll returns the plaintext of every secret in the store, by name. If any secrets cannot be
+// decrypted, it returns the others along with a *MultiError holding an *UndecryptableError for
+// each failure.
+func (c *Client) GetAll(ctx context.Context) (map[string][]byte, error) {
+	entries, err := c.store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		if name != store.KeyTemplateName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	plaintexts := make(map[string][]byte, len(names))
+	failures := &MultiError{}
+	for _, name := range names {
+		plaintext, err := c.decrypt(ctx, name, entries[name].Values)
+		if err != nil {
+			failures.Errors = append(failures.Errors, err)
+			continue
+		}
+		plaintexts[name] = plaintext
+	}
+	if len(failures.Errors) > 0 {
+		return plaintexts, failures
+	}
+	return plaintexts, nil
+}
+
+// Put encrypts plaintext under each of the keys in the store's template and saves it as a new
+// version of the secret called name. It returns an error wrapping ErrNoTemplate if the store has no
+// template.
+func (c *Client) Put(ctx context.Context, name string, plaintext []byte) error {
+	if name == store.KeyTemplateName {
+		return fmt.Errorf("%s is the key template, not a secret", name)
+	}
+	entries, err := c.store.GetAll()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	template, present := entries[store.KeyTemplateName]
+	if !present {
+		return fmt.Errorf("%w; create the store with biscuit kms init or biscuit passphrase init "+
+			"first", ErrNoTemplate)
+	}
+	var keys []store.Key
+	var values store.ValueList
+	for _, templateValue := range template.Values {
+		// A passphrase key without a salt and check value would get a new salt on every write,
+		// and would accept a mistyped passphrase.
+		if templateValue.KeyManager == keymanager.PassphraseLabel &&
+			keymanager.MatchPassphraseKeyID(templateValue.KeyID, nil) == "" {
+			return fmt.Errorf("the template's passphrase key %s has no salt or check value",
+				templateValue.KeyID)
+		}
+		value, err := envelope.Encrypt(ctx, c.keyManager, templateValue.Key, name, plaintext)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, templateValue.Key)
+		values = append(values, value)
+	}
+
+	updatedBy := identity.Caller(ctx, keys)
+	return c.store.Update(func(entries store.EntryMap) error {
+		entry := entries[name]
+		entry.SupersedeBy(values, entries.HistoryLimit(), updatedBy)
+		entries[name] = entry
+		return nil
+	})
+}
+
+// decrypt returns the plaintext of the first of values, in order of region priority, that can be
+// decrypted.
+func (c *Client) decrypt(ctx context.Context, name string, values store.ValueList) ([]byte, error) {
+	sorted := append(store.ValueList(nil), values...)
+	store.SortByKmsRegion(c.regionPriority)(sorted)
+	failures := &UndecryptableError{Name: name}
+	for _, value := range sorted {
+		plaintext, err := envelope.Decrypt(ctx, c.keyManager, value, name)
+		if err == nil {
+			return plaintext, nil
+		}
+		failures.Errors = append(failures.Errors, NewDecryptError(name, value, err))
+	}
+	return nil, failures
+}
+
+func (c *Client) keyManager(label string) (keymanager.KeyManager, error) {
+	if keyManager, present := c.keyManagers[label]; present {
+		return keyManager, nil
+	}
+	return keymanager.New(label)
+}
@@ -0,0 +1,152 @@
+package biscuit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedKeys is a KeyManager that wraps data keys by reversing them.
+type fixedKeys struct {
+	decryptErr error
+}
+
+func (f *fixedKeys) GenerateEnvelopeKey(_ context.Context, keyID, _ string) (keymanager.EnvelopeKey, error) {
+	plaintext := bytes.Repeat([]byte{'k'}, 32)
+	return keymanager.EnvelopeKey{ResolvedID: keyID, Plaintext: plaintext, Ciphertext: reverse(plaintext)}, nil
+}
+
+func (f *fixedKeys) Decrypt(_ context.Context, _ string, keyCiphertext []byte, _ string) ([]byte, error) {
+	if f.decryptErr != nil {
+		return nil, f.decryptErr
+	}
+	return reverse(keyCiphertext), nil
+}
+
+func (f *fixedKeys) Label() string {
+	return "fixed"
+}
+
+func reverse(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
+
+func writeStore(t *testing.T, contents string) string {
+	filename := filepath.Join(t.TempDir(), "secrets.yml")
+	assert.NoError(t, os.WriteFile(filename, []byte(contents), 0600))
+	return filename
+}
+
+const fixedTemplate = `_keys:
+- key_id: one
+  key_manager: fixed
+  algorithm: aesgcm256
+- key_id: two
+  key_manager: fixed
+  algorithm: secretbox
+`
+
+func TestClient_PutGet(t *testing.T) {
+	ctx := context.Background()
+	client, err := Open(writeStore(t, fixedTemplate), WithKeyManager(&fixedKeys{}))
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.Put(ctx, "launch_codes", []byte("0000")))
+	assert.NoError(t, client.Put(ctx, "launch_codes", []byte("1234")))
+	assert.NoError(t, client.Put(ctx, "motd", []byte("hello")))
+
+	plaintext, err := client.Get(ctx, "launch_codes")
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", string(plaintext))
+
+	all, err := client.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"launch_codes": []byte("1234"), "motd": []byte("hello")}, all)
+
+	assert.Error(t, client.Put(ctx, "_keys", []byte("0000")))
+	names, err := client.store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"launch_codes", "motd"}, names)
+
+	_, err = client.Get(ctx, "missing")
+	assert.True(t, errors.Is(err, ErrNameNotFound))
+	var notFound *store.NameNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "missing", notFound.Name)
+}
+
+func TestClient_DecryptErrors(t *testing.T) {
+	ctx := context.Background()
+	filename := writeStore(t, fixedTemplate)
+	writer, err := Open(filename, WithKeyManager(&fixedKeys{}))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Put(ctx, "launch_codes", []byte("0000")))
+
+	denied := errors.New("access denied")
+	reader, err := Open(filename, WithKeyManager(&fixedKeys{decryptErr: denied}))
+	assert.NoError(t, err)
+	_, err = reader.Get(ctx, "launch_codes")
+
+	var undecryptable *UndecryptableError
+	assert.True(t, errors.As(err, &undecryptable))
+	assert.Equal(t, "launch_codes", undecryptable.Name)
+	assert.Len(t, undecryptable.Errors, 2)
+	var decryptErr *DecryptError
+	assert.True(t, errors.As(err, &decryptErr))
+	assert.Equal(t, "fixed", decryptErr.KeyManager)
+	assert.True(t, errors.Is(err, denied))
+
+	assert.NoError(t, writer.Put(ctx, "motd", []byte("hello")))
+	all, err := reader.GetAll(ctx)
+	assert.Empty(t, all)
+	var multi *MultiError
+	assert.True(t, errors.As(err, &multi))
+	assert.Len(t, multi.Errors, 2)
+	assert.True(t, errors.As(err, &undecryptable))
+}
+
+func TestClient_NoTemplate(t *testing.T) {
+	client, err := Open(filepath.Join(t.TempDir(), "missing.yml"))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(client.Put(context.Background(), "name", []byte("value")), ErrNoTemplate))
+}
+
+func TestClient_Unencrypted(t *testing.T) {
+	ctx := context.Background()
+	client, err := Open(writeStore(t, "_keys:\n- algorithm: none\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Put(ctx, "motd", []byte("hello")))
+	plaintext, err := client.Get(ctx, "motd")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(plaintext))
+}
+
+func TestMultiError_IsAs(t *testing.T) {
+	denied := errors.New("access denied")
+	undecryptable := &UndecryptableError{Name: "launch_codes", Errors: []*DecryptError{
+		{Name: "launch_codes", KeyManager: "fixed", KeyID: "one", Err: denied},
+	}}
+	// Call the methods directly, since errors.Is and errors.As follow Unwrap() []error on their
+	// own in Go 1.20 and later.
+	multi := &MultiError{Errors: []error{errors.New("other"), undecryptable}}
+	assert.True(t, multi.Is(denied))
+	assert.False(t, multi.Is(ErrNameNotFound))
+	var decryptErr *DecryptError
+	assert.True(t, multi.As(&decryptErr))
+	assert.Equal(t, "one", decryptErr.KeyID)
+	var found *UndecryptableError
+	assert.True(t, multi.As(&found))
+	assert.Same(t, undecryptable, found)
+	assert.True(t, undecryptable.Is(denied))
+}
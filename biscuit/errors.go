@@ -0,0 +1,153 @@
+package biscuit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dcoker/biscuit/internal/aws/arn"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+)
+
+var (
+	// ErrNameNotFound matches, with errors.Is, the *store.NameNotFoundError returned when a
+	// secret does not exist. It is store.ErrNameNotFound.
+	ErrNameNotFound = store.ErrNameNotFound
+	// ErrNoTemplate is returned by Put when the store has no template entry listing the keys to
+	// encrypt secrets under. It is store.ErrNoTemplate.
+	ErrNoTemplate = store.ErrNoTemplate
+)
+
+// DecryptError reports that one value of a secret could not be decrypted.
+type DecryptError struct {
+	// Name is the name of the secret.
+	Name string
+	// KeyManager and KeyID identify the key that the value is encrypted under. They are empty
+	// for values that are not encrypted under a key.
+	KeyManager string
+	KeyID      string
+	// Region is the AWS region of the key, if the key manager is KMS.
+	Region string
+	Err    error
+}
+
+// NewDecryptError returns a DecryptError for a failure to decrypt value, one of the values of the
+// secret called name.
+func NewDecryptError(name string, value store.Value, err error) *DecryptError {
+	decryptErr := &DecryptError{
+		Name:       name,
+		KeyManager: value.KeyManager,
+		KeyID:      value.KeyID,
+		Err:        err,
+	}
+	if value.KeyManager == keymanager.KmsLabel {
+		if parsed, err := arn.New(value.KeyID); err == nil {
+			decryptErr.Region = parsed.Region
+		}
+	}
+	return decryptErr
+}
+
+func (e *DecryptError) Error() string {
+	if len(e.KeyManager) == 0 {
+		return fmt.Sprintf("%s: %s", e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s: decryption under %s key %s failed: %s", e.Name, e.KeyManager, e.KeyID, e.Err)
+}
+
+func (e *DecryptError) Unwrap() error {
+	return e.Err
+}
+
+// UndecryptableError is returned when none of the values of a secret could be decrypted.
+type UndecryptableError struct {
+	// Name is the name of the secret.
+	Name string
+	// Errors holds the failure of each value, in the order that the values were tried.
+	Errors []*DecryptError
+}
+
+func (e *UndecryptableError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%s has no values", e.Name)
+	}
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("could not decrypt %s with any of %d keys: %s", e.Name, len(e.Errors),
+		strings.Join(messages, "; "))
+}
+
+// Unwrap returns the failure of each value, so that errors.As can find a *DecryptError.
+func (e *UndecryptableError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Is reports whether any of the failures matches target. Versions of Go before 1.20 do not
+// follow Unwrap() []error.
+func (e *UndecryptableError) Is(target error) bool {
+	return anyIs(e.Unwrap(), target)
+}
+
+// As finds the first of the failures that matches target. Versions of Go before 1.20 do not
+// follow Unwrap() []error.
+func (e *UndecryptableError) As(target interface{}) bool {
+	return anyAs(e.Unwrap(), target)
+}
+
+// MultiError collects the failures of an operation on several secrets, such as GetAll.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the failures, so that errors.Is and errors.As can inspect each of them.
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is reports whether any of the failures matches target. Versions of Go before 1.20 do not
+// follow Unwrap() []error.
+func (e *MultiError) Is(target error) bool {
+	return anyIs(e.Errors, target)
+}
+
+// As finds the first of the failures that matches target. Versions of Go before 1.20 do not
+// follow Unwrap() []error.
+func (e *MultiError) As(target interface{}) bool {
+	return anyAs(e.Errors, target)
+}
+
+func anyIs(errs []error, target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyAs(errs []error, target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/dcoker/biscuit/biscuit"
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Operations supported by the agent.
+const (
+	agentGet    = "get"
+	agentList   = "list"
+	agentReload = "reload"
+)
+
+// agentRequest is sent to the agent by clients. Requests and responses are JSON objects, one per
+// line, and a connection may be used for any number of requests.
+type agentRequest struct {
+	Op   string `json:"op"`
+	Name string `json:"name,omitempty"`
+}
+
+type agentResponse struct {
+	Value *string  `json:"value,omitempty"`
+	Names []string `json:"names,omitempty"`
+	Error string   `json:"error,omitempty"`
+	// Kind classifies Error so that clients can exit with the same status as they would without
+	// the agent.
+	Kind string `json:"kind,omitempty"`
+}
+
+// Kinds of errors reported by the agent.
+const (
+	agentNotFound      = "not_found"
+	agentUndecryptable = "undecryptable"
+)
+
+// agentError is an error reported by the agent. It has the agent's message and wraps the typed
+// error that its kind stands for.
+type agentError struct {
+	message string
+	err     error
+}
+
+func (e *agentError) Error() string {
+	return e.message
+}
+
+func (e *agentError) Unwrap() error {
+	return e.err
+}
+
+type agent struct {
+	filename       *string
+	regionPriority *[]string
+	socket         *string
+	ttl            *time.Duration
+	pollInterval   *time.Duration
+}
+
+// NewAgent configures the command to serve decrypted secrets over a Unix socket.
+func NewAgent(c *kingpin.CmdClause) shared.Command {
+	return &agent{
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		socket:         shared.AgentSocketFlag(c),
+		ttl: c.Flag("ttl", "How long to keep decrypted secrets in memory. 0 disables caching.").
+			Default("5m").
+			Duration(),
+		pollInterval: c.Flag("poll-interval", "How often to check stores that are not on the local "+
+			"filesystem for changes. Local stores are watched for changes instead. 0 disables "+
+			"polling.").
+			Default("1m").
+			Duration(),
+	}
+}
+
+// Run runs the command.
+func (r *agent) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	cache, err := newSecretCache(database, *r.regionPriority, *r.ttl)
+	if err != nil {
+		return err
+	}
+	listener, err := listenUnix(*r.socket, 0600)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		err := store.Watch(ctx, database, *r.pollInterval, func() {
+			reloadCache(cache)
+		})
+		if err != nil {
+			log.Printf("No longer watching %s for changes: %s", *r.filename, err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	log.Printf("Serving secrets from %s on %s", *r.filename, *r.socket)
+	for {
+		conn, err := listener.Accept()
+		if ctx.Err() != nil {
+			log.Printf("Shutting down")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go serveAgentConn(ctx, cache, conn)
+	}
+}
+
+func serveAgentConn(ctx context.Context, cache *secretCache, conn net.Conn) {
+	defer conn.Close()
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var request agentRequest
+		if err := decoder.Decode(&request); err != nil {
+			if !errors.Is(err, io.EOF) {
+				_ = encoder.Encode(agentResponse{Error: fmt.Sprintf("invalid request: %s", err)})
+			}
+			return
+		}
+		var response agentResponse
+		switch request.Op {
+		case agentGet:
+			value, err := cache.get(ctx, request.Name)
+			if err != nil {
+				log.Printf("get: %s", err)
+				response.Error, response.Kind = err.Error(), agentErrorKind(err)
+			} else {
+				response.Value = &value
+			}
+		case agentList:
+			response.Names = cache.names()
+		case agentReload:
+			if err := reloadCache(cache); err != nil {
+				response.Error = err.Error()
+			}
+		default:
+			response.Error = fmt.Sprintf("unknown op '%s'", request.Op)
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// agentErrorKind returns the kind of err that clients should be told about, if any.
+func agentErrorKind(err error) string {
+	var notFound *store.NameNotFoundError
+	var undecryptable *biscuit.UndecryptableError
+	switch {
+	case errors.As(err, &notFound):
+		return agentNotFound
+	case errors.As(err, &undecryptable):
+		return agentUndecryptable
+	default:
+		return ""
+	}
+}
+
+func reloadCache(cache *secretCache) error {
+	changed, err := cache.reload()
+	if err != nil {
+		log.Printf("Could not reload: %s", err)
+		return err
+	}
+	if len(changed) > 0 {
+		log.Printf("Reloaded; %d %s changed", len(changed), stringsFunc.Pluralize("secret", len(changed)))
+	}
+	return nil
+}
+
+// listenUnix listens on a Unix socket with the given permissions. A stale socket left behind by a
+// process that exited uncleanly is replaced.
+func listenUnix(socket string, mode os.FileMode) (net.Listener, error) {
+	dir := filepath.Dir(socket)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Another user who owns the directory could replace the socket with their own. Directories
+	// owned by root, such as /tmp, are trusted.
+	if err := checkOwner(dir, info, true); err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+		return nil, fmt.Errorf("%s must not be writable by other users", dir)
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another process is already listening on %s", socket)
+	}
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// agentClient sends requests to a running agent.
+type agentClient struct {
+	conn    net.Conn
+	encoder *json.Encoder
+	decoder *json.Decoder
+}
+
+// dialAgent connects to the agent listening on socket. The socket must belong to the current user,
+// so that another user cannot impersonate the agent and return values of their choosing.
+func dialAgent(socket string) (*agentClient, error) {
+	info, err := os.Stat(socket)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the agent; is biscuit agent running? %w", err)
+	}
+	if err := checkOwner(socket, info, false); err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the agent; is biscuit agent running? %w", err)
+	}
+	return &agentClient{conn: conn, encoder: json.NewEncoder(conn), decoder: json.NewDecoder(conn)}, nil
+}
+
+func (a *agentClient) call(request agentRequest) (agentResponse, error) {
+	var response agentResponse
+	if err := a.encoder.Encode(request); err != nil {
+		return response, err
+	}
+	if err := a.decoder.Decode(&response); err != nil {
+		return response, err
+	}
+	if len(response.Error) > 0 {
+		return response, remoteAgentError(request.Name, response)
+	}
+	return response, nil
+}
+
+// remoteAgentError rebuilds the error in a response about the secret name.
+func remoteAgentError(name string, response agentResponse) error {
+	switch response.Kind {
+	case agentNotFound:
+		return &agentError{message: response.Error, err: &store.NameNotFoundError{Name: name}}
+	case agentUndecryptable:
+		return &agentError{message: response.Error, err: &biscuit.UndecryptableError{Name: name}}
+	default:
+		return errors.New(response.Error)
+	}
+}
+
+// get returns the plaintext of a secret.
+func (a *agentClient) get(name string) ([]byte, error) {
+	response, err := a.call(agentRequest{Op: agentGet, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if response.Value == nil {
+		return nil, fmt.Errorf("%s: the agent did not return a value", name)
+	}
+	return []byte(*response.Value), nil
+}
+
+func (a *agentClient) Close() error {
+	return a.conn.Close()
+}
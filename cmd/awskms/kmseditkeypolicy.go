@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/dcoker/biscuit/cmd/internal/shared"
@@ -15,7 +14,6 @@ import (
 )
 
 var (
-	errNoEditorFound        = errors.New("Set your editor preference with VISUAL or EDITOR environment variables.")
 	errNewPolicyIsZeroBytes = errors.New("No change: the new policy is empty.")
 	errFileUnchanged        = errors.New("No change: the new policy is the same as the existing policy.")
 )
@@ -79,16 +77,7 @@ func launchEditor(contents string) (string, error) {
 		return "", err
 	}
 
-	editor, err := findEditor()
-	if err != nil {
-		return "", err
-	}
-
-	cmd := exec.Command(editor, f.Name())
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := shared.RunEditor(f.Name()); err != nil {
 		return "", err
 	}
 
@@ -106,16 +95,6 @@ func launchEditor(contents string) (string, error) {
 	return newContents, nil
 }
 
-func findEditor() (string, error) {
-	for _, name := range []string{"VISUAL", "EDITOR"} {
-		candidate := os.Getenv(name)
-		if len(candidate) > 0 {
-			return candidate, nil
-		}
-	}
-	return "", errNoEditorFound
-}
-
 func prettifyJSON(content string) (string, error) {
 	var v interface{}
 	if err := json.Unmarshal([]byte(content), &v); err != nil {
@@ -61,7 +61,10 @@ type grantDetails struct {
 
 // Run runs the command.
 func (w *kmsGrantsCreate) Run(ctx context.Context) error {
-	database := store.NewFileStore(*w.filename)
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 	values, err := database.Get(*w.name)
 	if err != nil {
 		return err
@@ -34,7 +34,10 @@ type grantsForOneAlias struct {
 
 // Run runs the command.
 func (w *kmsGrantsList) Run(ctx context.Context) error {
-	database := store.NewFileStore(*w.filename)
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 	values, err := database.Get(*w.name)
 	if err != nil {
 		return err
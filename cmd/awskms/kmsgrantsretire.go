@@ -23,7 +23,10 @@ func NewKmsGrantsRetire(c *kingpin.CmdClause) shared.Command {
 }
 
 func (w *kmsGrantsRetire) Run(ctx context.Context) error {
-	database := store.NewFileStore(*w.filename)
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 	values, err := database.Get(*w.name)
 	if err != nil {
 		return err
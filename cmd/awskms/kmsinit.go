@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"sort"
 	"strings"
@@ -45,6 +44,7 @@ type kmsInit struct {
 	filename,
 	algorithm,
 	cloudformationTemplateURL *string
+	lockTimeout               *time.Duration
 	keyCloudformationTemplate string
 }
 
@@ -83,6 +83,7 @@ func NewKmsInit(c *kingpin.CmdClause, keyCloudformationTemplate string) shared.C
 		String()
 	params.filename = shared.FilenameFlag(c)
 	params.algorithm = shared.AlgorithmFlag(c)
+	params.lockTimeout = shared.LockTimeoutFlag(c)
 	return params
 }
 
@@ -93,45 +94,53 @@ func (w *kmsInit) Run(ctx context.Context) error {
 		return err
 	}
 
-	database := store.NewFileStore(*w.filename)
-
-	// If the file exists, we'll make changes to its template rather than replace it.
-	keyConfigs, err := database.Get(store.KeyTemplateName)
-	if err != nil && !(err == store.ErrNameNotFound || errors.Is(err, fs.ErrNotExist)) {
+	database, err := store.Open(*w.filename, store.LockTimeout(*w.lockTimeout))
+	if err != nil {
 		return err
 	}
 
-	// Convert keyConfigs into a map of KeyID -> Value so that we can replace any existing
-	// entries for these keys. This allows the algorithm parameter to change w/o creating
-	// duplicate entries, and leaves other entries alone.
-	keyIDToValue := make(map[string]store.Value)
-	for _, value := range keyConfigs {
-		keyIDToValue[keymanager.KmsLabel+value.KeyID] = value
-	}
+	// If the file exists, we'll make changes to its template rather than replace it.
+	err = database.Update(func(entries store.EntryMap) error {
+		template := entries[store.KeyTemplateName]
+		keyConfigs := template.Values
+
+		// Convert keyConfigs into a map of KeyID -> Value so that we can replace any existing
+		// entries for these keys. This allows the algorithm parameter to change w/o creating
+		// duplicate entries, and leaves other entries alone.
+		keyIDToValue := make(map[string]store.Value)
+		for _, value := range keyConfigs {
+			keyIDToValue[keymanager.KmsLabel+value.KeyID] = value
+		}
 
-	// Iterate over the discovered/created keys and set values for them in keyIDToValue.
-	for _, keyArn := range regionKeys {
-		keyIDToValue[keymanager.KmsLabel+keyArn] = store.Value{
-			Key: store.Key{
-				KeyID:      keyArn,
-				KeyManager: keymanager.KmsLabel,
-				Algorithm:  *w.algorithm,
-			},
+		// Iterate over the discovered/created keys and set values for them in keyIDToValue.
+		for _, keyArn := range regionKeys {
+			keyIDToValue[keymanager.KmsLabel+keyArn] = store.Value{
+				Key: store.Key{
+					KeyID:      keyArn,
+					KeyManager: keymanager.KmsLabel,
+					Algorithm:  *w.algorithm,
+				},
+			}
 		}
-	}
 
-	// Turn keyIDToValue back into an array by converting the map values into a list.
-	var updatedTemplate []store.Value
-	for _, v := range keyIDToValue {
-		updatedTemplate = append(updatedTemplate, v)
+		// Turn keyIDToValue back into an array by converting the map values into a list.
+		var updatedTemplate []store.Value
+		for _, v := range keyIDToValue {
+			updatedTemplate = append(updatedTemplate, v)
+		}
+		template.Values = updatedTemplate
+		entries[store.KeyTemplateName] = template
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("The template used by %s has been updated to include %s: %s.\n",
 		*w.filename,
 		stringsFunc.Pluralize("key", len(regionKeys)),
 		stringStringMapValues(regionKeys))
-
-	return database.Put(store.KeyTemplateName, updatedTemplate)
+	return nil
 }
 
 func collectRegionInfo(ctx context.Context, stackName, keyAlias string, regions []string) (map[string]string, []string, error) {
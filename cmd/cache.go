@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dcoker/biscuit/store"
+)
+
+// secretCache decrypts the secrets in a store on demand, and keeps the plaintext in memory for up
+// to ttl. It is safe for concurrent use.
+type secretCache struct {
+	database       store.Store
+	regionPriority []string
+	ttl            time.Duration
+
+	mu      sync.Mutex
+	entries store.EntryMap
+	cached  map[string]cachedSecret
+}
+
+// noExpiry is a ttl that keeps plaintext in memory until the secret changes.
+const noExpiry time.Duration = -1
+
+type cachedSecret struct {
+	plaintext string
+	expires   time.Time
+}
+
+// newSecretCache reads the entries of database. Nothing is decrypted until it is requested. A ttl
+// of zero disables caching, and noExpiry keeps plaintext until the secret changes.
+func newSecretCache(database store.Store, regionPriority []string, ttl time.Duration) (*secretCache, error) {
+	c := &secretCache{
+		database:       database,
+		regionPriority: regionPriority,
+		ttl:            ttl,
+		cached:         make(map[string]cachedSecret),
+	}
+	_, err := c.reload()
+	return c, err
+}
+
+// get returns the plaintext of a secret.
+func (c *secretCache) get(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	entry, present := c.entries[name]
+	cached, hit := c.cached[name]
+	c.mu.Unlock()
+	if !present || name == store.KeyTemplateName {
+		return "", &store.NameNotFoundError{Name: name}
+	}
+	if hit && (c.ttl == noExpiry || time.Now().Before(cached.expires)) {
+		return cached.plaintext, nil
+	}
+
+	values := make(store.ValueList, len(entry.Values))
+	copy(values, entry.Values)
+	store.SortByKmsRegion(c.regionPriority)(values)
+	plaintext, err := decryptAny(ctx, name, values)
+	if err != nil {
+		return "", err
+	}
+	if c.ttl == 0 {
+		return string(plaintext), nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Don't cache a value that was replaced while it was being decrypted.
+	if reflect.DeepEqual(c.entries[name], entry) {
+		expires := time.Now().Add(c.ttl)
+		c.cached[name] = cachedSecret{plaintext: string(plaintext), expires: expires}
+		if c.ttl == noExpiry {
+			return string(plaintext), nil
+		}
+		time.AfterFunc(c.ttl, func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.cached[name].expires.Equal(expires) {
+				delete(c.cached, name)
+			}
+		})
+	}
+	return string(plaintext), nil
+}
+
+// names returns the sorted names of the secrets.
+func (c *secretCache) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for name := range c.entries {
+		if name != store.KeyTemplateName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reload reads the entries of the store again, and forgets the plaintext of those that changed.
+// It returns the names of the entries that were added, changed, or removed.
+func (c *secretCache) reload() ([]string, error) {
+	entries, err := c.database.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var changed []string
+	for name, entry := range entries {
+		if previous, present := c.entries[name]; !present || !reflect.DeepEqual(previous, entry) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range c.entries {
+		if _, present := entries[name]; !present {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range changed {
+		delete(c.cached, name)
+	}
+	c.entries = entries
+	sort.Strings(changed)
+	return changed, nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type deleteCmd struct {
+	names       *[]string
+	filename    *string
+	lockTimeout *time.Duration
+	dryRun      *bool
+	force       *bool
+}
+
+// NewDelete configures the command to delete secrets.
+func NewDelete(c *kingpin.CmdClause) shared.Command {
+	return &deleteCmd{
+		names: c.Arg("name", "Names of the secrets to delete. Shell-style glob patterns (ex: 'db_*') "+
+			"are supported.").Required().Strings(),
+		dryRun: c.Flag("dry-run", "List the secrets that would be deleted without deleting them.").
+			Short('n').
+			Bool(),
+		force:       c.Flag("force", "Allow deletion of the "+store.KeyTemplateName+" template.").Bool(),
+		filename:    shared.FilenameFlag(c),
+		lockTimeout: shared.LockTimeoutFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *deleteCmd) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := matchNames(entries, *r.names, *r.force)
+	if errors.Is(err, errKeyTemplate) {
+		return fmt.Errorf("%w; use --force to delete it", err)
+	} else if err != nil {
+		return err
+	}
+	if *r.dryRun {
+		for _, name := range names {
+			fmt.Printf("Would delete: %s\n", name)
+		}
+		return nil
+	}
+	return database.Delete(names...)
+}
+
+// errKeyTemplate is returned when the template entry is named where a secret is expected.
+var errKeyTemplate = fmt.Errorf("%s is the key template, not a secret", store.KeyTemplateName)
+
+// checkSecretName returns errKeyTemplate if name is the template entry, which commands that read
+// or write a single secret must not treat as one.
+func checkSecretName(name string) error {
+	if name == store.KeyTemplateName {
+		return errKeyTemplate
+	}
+	return nil
+}
+
+// matchNames returns the sorted, de-duplicated names in entries that match any of the patterns.
+// Patterns without glob metacharacters must match an entry exactly. The template entry is only
+// matched by a glob when includeTemplate is set, and naming it explicitly without
+// includeTemplate is an error.
+func matchNames(entries store.EntryMap, patterns []string, includeTemplate bool) ([]string, error) {
+	matched := make(map[string]struct{})
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			if _, present := entries[pattern]; !present {
+				return nil, &store.NameNotFoundError{Name: pattern}
+			}
+			if pattern == store.KeyTemplateName && !includeTemplate {
+				return nil, errKeyTemplate
+			}
+			matched[pattern] = struct{}{}
+			continue
+		}
+		found := false
+		for name := range entries {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", pattern, err)
+			}
+			if !ok || (name == store.KeyTemplateName && !includeTemplate) {
+				continue
+			}
+			matched[name] = struct{}{}
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: no secrets match", pattern)
+		}
+	}
+	var names []string
+	for name := range matched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/aws/arn"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type describe struct {
+	names    *[]string
+	filename *string
+	format   *string
+}
+
+// NewDescribe configures the command to show how secrets are encrypted.
+func NewDescribe(c *kingpin.CmdClause) shared.Command {
+	return &describe{
+		names: c.Arg("name", "Names of the secrets to describe. Shell-style glob patterns are "+
+			"supported. If omitted, all secrets are described.").Strings(),
+		filename: shared.FilenameFlag(c),
+		format: c.Flag("format", "Output format.").
+			Default("table").
+			Enum("table", "json"),
+	}
+}
+
+// describedValue is the description of one encrypted copy of a secret. None of its fields
+// require decrypting anything.
+type describedValue struct {
+	KeyManager    string     `json:"key_manager,omitempty"`
+	KeyID         string     `json:"key_id,omitempty"`
+	Algorithm     string     `json:"algorithm"`
+	Account       string     `json:"account,omitempty"`
+	Region        string     `json:"region,omitempty"`
+	Alias         string     `json:"alias,omitempty"`
+	FormatVersion int        `json:"format_version"`
+	RotatedAt     *time.Time `json:"rotated_at,omitempty"`
+}
+
+type describedEntry struct {
+	Name    string           `json:"name"`
+	Version int              `json:"version"`
+	Values  []describedValue `json:"values"`
+	// MissingRegions lists the regions of the KMS keys in the template that none of the values
+	// are encrypted under.
+	MissingRegions []string `json:"missing_regions,omitempty"`
+}
+
+// Run runs the command.
+func (r *describe) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.names)
+	if err != nil {
+		return err
+	}
+	templateRegions := kmsRegions(entries[store.KeyTemplateName].Values)
+
+	described := []describedEntry{}
+	for _, name := range names {
+		entry := entries[name]
+		result := describedEntry{Name: name, Version: entry.Version(), Values: []describedValue{}}
+		for _, value := range entry.Values {
+			result.Values = append(result.Values, describeValue(value))
+		}
+		present := make(map[string]struct{})
+		for _, region := range kmsRegions(entry.Values) {
+			present[region] = struct{}{}
+		}
+		for _, region := range templateRegions {
+			if _, ok := present[region]; !ok {
+				result.MissingRegions = append(result.MissingRegions, region)
+			}
+		}
+		described = append(described, result)
+	}
+
+	if *r.format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(described)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKEY MANAGER\tALGORITHM\tACCOUNT\tREGION\tALIAS\tKEY ID")
+	for _, entry := range described {
+		for _, value := range entry.Values {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Name, orDash(value.KeyManager),
+				value.Algorithm, orDash(value.Account), orDash(value.Region), orDash(value.Alias),
+				orDash(value.KeyID))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, entry := range described {
+		if len(entry.MissingRegions) > 0 {
+			fmt.Printf("WARNING: %s is not encrypted in %s, which %s includes.\n", entry.Name,
+				strings.Join(entry.MissingRegions, ", "), store.KeyTemplateName)
+		}
+	}
+	return nil
+}
+
+// describeValue returns the description of a value, resolving the account, region, and alias of
+// KMS key ARNs.
+func describeValue(value store.Value) describedValue {
+	result := describedValue{
+		KeyManager:    value.KeyManager,
+		KeyID:         value.KeyID,
+		Algorithm:     value.Algorithm,
+		FormatVersion: value.FormatVersion,
+		RotatedAt:     value.RotatedAt,
+	}
+	if value.KeyManager != keymanager.KmsLabel {
+		return result
+	}
+	parsed, err := arn.New(value.KeyID)
+	if err != nil {
+		return result
+	}
+	result.Account = parsed.AccountID
+	result.Region = parsed.Region
+	if parsed.IsKmsAlias() {
+		result.Alias = "alias/" + parsed.Resource
+	}
+	return result
+}
+
+// kmsRegions returns the sorted, distinct regions of the KMS keys in values.
+func kmsRegions(values store.ValueList) []string {
+	set := make(map[string]struct{})
+	for _, value := range values {
+		if region := valueRegion(value); region != "" {
+			set[region] = struct{}{}
+		}
+	}
+	return sortedKeys(set)
+}
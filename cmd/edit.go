@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/identity"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// sharedMemoryDir is a tmpfs on most Linux systems. Plaintext written there never reaches disk.
+const sharedMemoryDir = "/dev/shm"
+
+var errModifiedWhileEditing = errors.New("the secret was modified by someone else while you were editing; " +
+	"no changes were saved")
+
+type edit struct {
+	name           *string
+	filename       *string
+	regionPriority *[]string
+	lockTimeout    *time.Duration
+}
+
+// NewEdit configures the command to edit a secret in an editor.
+func NewEdit(c *kingpin.CmdClause) shared.Command {
+	return &edit{
+		name:           c.Arg("name", "Name of the secret to edit.").Required().String(),
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		lockTimeout:    shared.LockTimeoutFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *edit) Run(ctx context.Context) error {
+	if err := checkSecretName(*r.name); err != nil {
+		return err
+	}
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	original, err := database.Get(*r.name)
+	if err != nil {
+		return err
+	}
+	values := make(store.ValueList, len(original))
+	copy(values, original)
+	store.SortByKmsRegion(*r.regionPriority)(values)
+	plaintext, err := decryptAny(ctx, *r.name, values)
+	if err != nil {
+		return err
+	}
+
+	edited, err := editInTempFile(plaintext)
+	if err != nil {
+		return err
+	}
+	// Most editors append a newline when saving; don't let that alter secrets without one.
+	if !bytes.HasSuffix(plaintext, []byte("\n")) {
+		edited = bytes.TrimSuffix(edited, []byte("\n"))
+	}
+	if bytes.Equal(plaintext, edited) {
+		fmt.Fprintf(os.Stderr, "No change.\n")
+		return nil
+	}
+
+	// Re-encrypt under the keys this secret currently uses, which may differ from the template.
+	var keys []store.Key
+	for _, value := range original {
+		keys = append(keys, value.Key)
+	}
+	valueList, err := encryptAll(ctx, keys, *r.name, edited)
+	if err != nil {
+		return err
+	}
+	updatedBy := identity.Caller(ctx, keys)
+	return database.Update(func(entries store.EntryMap) error {
+		entry := entries[*r.name]
+		if !reflect.DeepEqual(entry.Values, original) {
+			return errModifiedWhileEditing
+		}
+		entry.SupersedeBy(valueList, entries.HistoryLimit(), updatedBy)
+		entries[*r.name] = entry
+		return nil
+	})
+}
+
+// editInTempFile writes contents to a private temporary file, opens it in the user's editor,
+// and returns the edited contents. The file is overwritten and removed before returning.
+func editInTempFile(contents []byte) ([]byte, error) {
+	parent := ""
+	if info, err := os.Stat(sharedMemoryDir); err == nil && info.IsDir() {
+		parent = sharedMemoryDir
+	}
+	dir, err := os.MkdirTemp(parent, shared.ProgName)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "secret")
+	if err := os.WriteFile(filename, contents, 0600); err != nil {
+		return nil, err
+	}
+	defer shred(filename)
+
+	if err := shared.RunEditor(filename); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filename)
+}
+
+// shred overwrites a file with zeros before removing it. This is best-effort: editors may have
+// written backup or swap files, and filesystems may not overwrite in place.
+func shred(filename string) {
+	if info, err := os.Stat(filename); err == nil {
+		if f, err := os.OpenFile(filename, os.O_WRONLY, 0); err == nil {
+			_, _ = f.Write(make([]byte, info.Size()))
+			_ = f.Sync()
+			f.Close()
+		}
+	}
+	os.Remove(filename)
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// ExitStatus is returned by commands that want the process to exit with a specific status code.
+// The command is responsible for having reported any problems already.
+type ExitStatus int
+
+func (e ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+type execCmd struct {
+	filename       *string
+	regionPriority *[]string
+	only           *[]string
+	prefix         *string
+	command        *[]string
+}
+
+// NewExec configures the command to run a child process with secrets in its environment.
+func NewExec(c *kingpin.CmdClause) shared.Command {
+	params := &execCmd{}
+	params.filename = shared.FilenameFlag(c)
+	params.regionPriority = shared.AwsRegionPriorityFlag(c)
+	onlyFlag := c.Flag("only", "Comma-delimited list of secret names or glob patterns to inject. "+
+		"By default, all secrets are injected.").PlaceHolder("NAME,...")
+	only := (&shared.CommaSeparatedList{}).Name("only")
+	onlyFlag.SetValue(only)
+	params.only = &only.V
+	params.prefix = c.Flag("prefix", "Prefix added to the name of each environment variable.").
+		PlaceHolder("PREFIX").
+		String()
+	params.command = c.Arg("command", "Command to run, followed by its arguments. Use -- to "+
+		"separate the command from biscuit's flags.").Required().Strings()
+	return params
+}
+
+// Run runs the command.
+func (r *execCmd) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+
+	names, err := selectNames(entries, *r.only)
+	if err != nil {
+		return err
+	}
+
+	secrets := make(map[string]string)
+	sources := make(map[string]string)
+	for _, name := range names {
+		variable := envName(*r.prefix, name)
+		if other, present := sources[variable]; present {
+			return fmt.Errorf("secrets %s and %s both map to environment variable %s", other, name,
+				variable)
+		}
+		sources[variable] = name
+
+		values := entries[name].Values
+		store.SortByKmsRegion(*r.regionPriority)(values)
+		plaintext, err := decryptAny(ctx, name, values)
+		if err != nil {
+			return err
+		}
+		secrets[variable] = string(plaintext)
+	}
+
+	return runWithEnvironment(*r.command, secrets)
+}
+
+// envName converts a secret name to a valid environment variable name by upper-casing it and
+// replacing characters other than letters, digits, and underscores with underscores.
+func envName(prefix, name string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, prefix+name)
+	if len(mapped) == 0 || (mapped[0] >= '0' && mapped[0] <= '9') {
+		mapped = "_" + mapped
+	}
+	return mapped
+}
+
+// runWithEnvironment runs command with secrets added to the current environment, forwarding
+// signals received by this process to the child. If the child exits unsuccessfully, an
+// ExitStatus with the child's status is returned.
+func runWithEnvironment(command []string, secrets map[string]string) error {
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	for _, kv := range os.Environ() {
+		if _, overridden := secrets[strings.SplitN(kv, "=", 2)[0]]; !overridden {
+			child.Env = append(child.Env, kv)
+		}
+	}
+	for variable, value := range secrets {
+		child.Env = append(child.Env, variable+"="+value)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = child.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := child.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return ExitStatus(128 + int(status.Signal()))
+		}
+		return ExitStatus(exitErr.ExitCode())
+	}
+	return err
+}
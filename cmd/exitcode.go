@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/biscuit"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+)
+
+// Exit statuses for errors that scripts may want to handle differently. Other errors exit with
+// status 1. An ExitStatus passed through from the command run by exec may coincide with these.
+const (
+	exitNameNotFound  = 3
+	exitNoTemplate    = 4
+	exitUndecryptable = 5
+	exitUnsupported   = 6
+)
+
+// ExitCode returns the status that the process should exit with after a command fails with err.
+func ExitCode(err error) int {
+	var exitStatus ExitStatus
+	var notFound *store.NameNotFoundError
+	var undecryptable *biscuit.UndecryptableError
+	var unsupportedAlgorithm *algorithms.UnsupportedAlgorithmError
+	var unsupportedKeyManager *keymanager.UnsupportedKeyManagerError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitStatus):
+		return int(exitStatus)
+	case errors.As(err, &notFound):
+		return exitNameNotFound
+	case errors.Is(err, store.ErrNoTemplate):
+		return exitNoTemplate
+	// A value may be undecryptable because its algorithm or key manager is unsupported; the
+	// secret being unreadable is what matters to the caller.
+	case errors.As(err, &undecryptable):
+		return exitUndecryptable
+	case errors.As(err, &unsupportedAlgorithm), errors.As(err, &unsupportedKeyManager):
+		return exitUnsupported
+	default:
+		return 1
+	}
+}
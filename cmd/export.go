@@ -2,56 +2,273 @@ package cmd
 
 import (
 	"context"
-	"errors"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"os"
+	"path"
+	"regexp"
+	"strings"
 
+	"github.com/dcoker/biscuit/biscuit"
 	"github.com/dcoker/biscuit/cmd/internal/shared"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
 	"github.com/dcoker/biscuit/internal/yaml"
 	"github.com/dcoker/biscuit/store"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+// Export formats.
+const (
+	exportYAML      = "yaml"
+	exportJSON      = "json"
+	exportDotenv    = "dotenv"
+	exportShell     = "shell"
+	exportK8sSecret = "k8s-secret"
+	exportTfvars    = "tfvars"
+)
+
+var k8sSecretKeyRegexp = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
 type export struct {
 	filename       *string
 	regionPriority *[]string
+	format         *string
+	only           *[]string
+	exclude        *[]string
+	k8sName        *string
+	k8sNamespace   *string
 }
 
 // NewExport configures the flags for export.
 func NewExport(c *kingpin.CmdClause) shared.Command {
-	return &export{
-		filename:       shared.FilenameFlag(c),
-		regionPriority: shared.AwsRegionPriorityFlag(c),
-	}
+	params := &export{}
+	params.filename = shared.FilenameFlag(c)
+	params.regionPriority = shared.AwsRegionPriorityFlag(c)
+	params.format = c.Flag("format", "Output format. dotenv and shell convert the names of the "+
+		"secrets to environment variable names the same way that exec does.").
+		Default(exportYAML).
+		Enum(exportYAML, exportJSON, exportDotenv, exportShell, exportK8sSecret, exportTfvars)
+	onlyFlag := c.Flag("only", "Comma-delimited list of secret names or glob patterns to export. "+
+		"By default, all secrets are exported.").PlaceHolder("NAME,...")
+	only := (&shared.CommaSeparatedList{}).Name("only")
+	onlyFlag.SetValue(only)
+	params.only = &only.V
+	excludeFlag := c.Flag("exclude", "Comma-delimited list of secret names or glob patterns "+
+		"not to export.").PlaceHolder("NAME,...")
+	exclude := (&shared.CommaSeparatedList{}).Name("exclude")
+	excludeFlag.SetValue(exclude)
+	params.exclude = &exclude.V
+	params.k8sName = c.Flag("k8s-name", "Name of the Secret when using --format=k8s-secret.").
+		Default("biscuit").
+		String()
+	params.k8sNamespace = c.Flag("k8s-namespace", "Namespace of the Secret when using "+
+		"--format=k8s-secret. If omitted, the manifest does not specify a namespace.").
+		PlaceHolder("NAMESPACE").
+		String()
+	return params
 }
 
 // Run the command.
 func (r *export) Run(ctx context.Context) error {
-	database := store.NewFileStore(*r.filename)
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
 	entries, err := database.GetAll()
 	if err != nil {
 		return err
 	}
-	errs := 0
-	for name, values := range entries {
-		if name == store.KeyTemplateName {
-			continue
+	selected, err := selectNames(entries, *r.only)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, name := range selected {
+		excluded, err := matchesAny(*r.exclude, name)
+		if err != nil {
+			return err
 		}
+		if !excluded {
+			names = append(names, name)
+		}
+	}
 
+	failures := &biscuit.MultiError{}
+	var exported []string
+	secrets := make(map[string]string)
+	for _, name := range names {
+		values := entries[name].Values
 		store.SortByKmsRegion(*r.regionPriority)(values)
-		for _, v := range values {
-			bytes, err := decryptOneValue(ctx, v, name)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: unable to decrypt, skipping: %s\n", err)
-				errs++
-				continue
-			}
-			fmt.Print(yaml.ToString(map[string]string{name: string(bytes)}))
-			break
+		plaintext, err := decryptAny(ctx, name, values)
+		if err != nil {
+			failures.Errors = append(failures.Errors, err)
+			continue
 		}
+		exported = append(exported, name)
+		secrets[name] = string(plaintext)
+	}
+	output, err := r.render(exported, secrets)
+	if err != nil {
+		return err
 	}
-	if errs > 0 {
-		return errors.New("there were errors exporting")
+	fmt.Print(output)
+	if len(failures.Errors) > 0 {
+		return fmt.Errorf("skipped %d %s that could not be decrypted:\n%w", len(failures.Errors),
+			stringsFunc.Pluralize("secret", len(failures.Errors)), failures)
 	}
 	return nil
 }
+
+// render returns secrets, in the order of names, in the selected format.
+func (r *export) render(names []string, secrets map[string]string) (string, error) {
+	switch *r.format {
+	case exportJSON:
+		var b strings.Builder
+		encoder := json.NewEncoder(&b)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(secrets)
+		return b.String(), err
+	case exportDotenv, exportShell:
+		variables, err := renameSecrets(names, func(name string) (string, error) {
+			return envName("", name), nil
+		})
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		for _, name := range names {
+			if *r.format == exportShell {
+				fmt.Fprintf(&b, "export %s=%s\n", variables[name], shellQuote(secrets[name]))
+			} else {
+				fmt.Fprintf(&b, "%s=%s\n", variables[name], dotenvQuote(secrets[name]))
+			}
+		}
+		return b.String(), nil
+	case exportTfvars:
+		variables, err := renameSecrets(names, func(name string) (string, error) {
+			return tfvarsName(name), nil
+		})
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s = %s\n", variables[name], hclQuote(secrets[name]))
+		}
+		return b.String(), nil
+	case exportK8sSecret:
+		keys, err := renameSecrets(names, func(name string) (string, error) {
+			if !k8sSecretKeyRegexp.MatchString(name) {
+				return "", fmt.Errorf("%s cannot be a key of a Kubernetes Secret; keys may only "+
+					"contain letters, digits, and -._", name)
+			}
+			return name, nil
+		})
+		if err != nil {
+			return "", err
+		}
+		manifest := k8sSecret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   k8sMetadata{Name: *r.k8sName, Namespace: *r.k8sNamespace},
+			Type:       "Opaque",
+			Data:       make(map[string]string),
+		}
+		for _, name := range names {
+			manifest.Data[keys[name]] = base64.StdEncoding.EncodeToString([]byte(secrets[name]))
+		}
+		return yaml.ToString(manifest), nil
+	default:
+		if len(secrets) == 0 {
+			return "", nil
+		}
+		return yaml.ToString(secrets), nil
+	}
+}
+
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// renameSecrets maps each of names to the name it is exported as, failing if two secrets would be
+// exported under the same name.
+func renameSecrets(names []string, rename func(name string) (string, error)) (map[string]string, error) {
+	renamed := make(map[string]string)
+	sources := make(map[string]string)
+	for _, name := range names {
+		exportedAs, err := rename(name)
+		if err != nil {
+			return nil, err
+		}
+		if other, present := sources[exportedAs]; present {
+			return nil, fmt.Errorf("secrets %s and %s both export as %s", other, name, exportedAs)
+		}
+		sources[exportedAs] = name
+		renamed[name] = exportedAs
+	}
+	return renamed, nil
+}
+
+// matchesAny returns true if name matches any of the glob patterns.
+func matchesAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// shellQuote quotes s for POSIX shells, which treat everything between single quotes literally.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dotenvQuote quotes s for .env files. Single quotes are used where possible because most
+// implementations read them literally; values containing single quotes or line breaks are double
+// quoted with backslash escapes instead, escaping $ so that it is not expanded.
+func dotenvQuote(s string) string {
+	if !strings.ContainsAny(s, "'\n\r") {
+		return "'" + s + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`,
+		"$", `\$`).Replace(s) + `"`
+}
+
+// hclQuote returns s as an HCL string literal, escaping template sequences so that Terraform does
+// not interpolate them.
+func hclQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`,
+		"${", "$${", "%{", "%%{").Replace(s) + `"`
+}
+
+// tfvarsName converts a secret name to a valid Terraform variable name by replacing characters
+// other than letters, digits, underscores, and dashes with underscores.
+func tfvarsName(name string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if len(mapped) == 0 || (mapped[0] >= '0' && mapped[0] <= '9') || mapped[0] == '-' {
+		mapped = "_" + mapped
+	}
+	return mapped
+}
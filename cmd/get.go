@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
-	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/biscuit"
 	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/envelope"
 	"github.com/dcoker/biscuit/keymanager"
 	"github.com/dcoker/biscuit/store"
 	"github.com/mattn/go-isatty"
@@ -18,6 +20,9 @@ type get struct {
 	writeTo        *string
 	filename       *string
 	regionPriority *[]string
+	version        *int
+	agent          *bool
+	socket         *string
 }
 
 // NewGet constructs the command to decrypt an encrypted value.
@@ -30,31 +35,23 @@ func NewGet(c *kingpin.CmdClause) shared.Command {
 			Short('o').
 			String(),
 		filename: shared.FilenameFlag(c),
+		version: c.Flag("at-version", "Read version N of the secret instead of the current version. See "+
+			"the history command. (This is not --version, which prints the version of biscuit.)").
+			PlaceHolder("N").
+			Int(),
+		agent: c.Flag("agent", "Read the secret from a running biscuit agent instead of "+
+			"decrypting it. FILE is not read.").
+			Bool(),
+		socket: shared.AgentSocketFlag(c),
 	}
 }
 
 // Run the command.
 func (r *get) Run(ctx context.Context) error {
-	database := store.NewFileStore(*r.filename)
-	values, err := database.Get(*r.name)
-	if err != nil {
+	if err := checkSecretName(*r.name); err != nil {
 		return err
 	}
-	store.SortByKmsRegion(*r.regionPriority)(values)
-	// There may be multiple values, but we assume that each one represents the same contents
-	// so we stop after processing just one successfully.
-	var plaintext []byte
-	for _, value := range values {
-		plaintext, err = decryptOneValue(ctx, value, *r.name)
-		if err != nil {
-			fmt.Fprintf(os.Stderr,
-				"Warning: decryption under %s failed: %s\n",
-				value.KeyManager,
-				err)
-			continue
-		}
-		break
-	}
+	plaintext, err := r.plaintext(ctx)
 	if err != nil {
 		return err
 	}
@@ -70,38 +67,57 @@ func (r *get) Run(ctx context.Context) error {
 	return nil
 }
 
-func decryptOneValue(ctx context.Context, value store.Value, name string) ([]byte, error) {
-	algo, err := algorithms.Get(value.Algorithm)
-	if err != nil {
-		return []byte{}, err
-	}
-	var keyPlaintext []byte
-	if algo.NeedsKey() {
-		keyPlaintext, err = getPlaintextKeyFromManager(ctx, value, name)
+func (r *get) plaintext(ctx context.Context) ([]byte, error) {
+	if *r.agent {
+		if *r.version != 0 {
+			return nil, errors.New("--at-version cannot be used with --agent")
+		}
+		client, err := dialAgent(*r.socket)
 		if err != nil {
 			return nil, err
 		}
+		defer client.Close()
+		return client.get(*r.name)
 	}
-	decoded, err := value.GetCiphertext()
-	if err != nil {
-		return []byte{}, err
-	}
-	plaintext, err := algo.Decrypt(keyPlaintext, decoded)
-	return plaintext, err
-}
 
-func getPlaintextKeyFromManager(ctx context.Context, value store.Value, name string) ([]byte, error) {
-	keyManager, err := keymanager.New(value.KeyManager)
+	database, err := store.Open(*r.filename)
 	if err != nil {
-		return []byte{}, err
+		return nil, err
 	}
-	keyCiphertext, err := value.GetKeyCiphertext()
+	entry, err := database.GetEntry(*r.name)
 	if err != nil {
-		return []byte{}, err
+		return nil, err
 	}
-	keyPlaintext, err := keyManager.Decrypt(ctx, value.Key.KeyID, keyCiphertext, name)
-	if err != nil {
-		return []byte{}, err
+	values := entry.Values
+	if *r.version != 0 {
+		if values, err = entry.Revision(*r.version); err != nil {
+			return nil, err
+		}
+	}
+	store.SortByKmsRegion(*r.regionPriority)(values)
+	return decryptAny(ctx, *r.name, values)
+}
+
+// decryptAny returns the plaintext of the first of values that can be decrypted, warning about
+// the values that failed before it. There may be multiple values, but we assume that each one
+// represents the same contents so we stop after processing just one successfully. If none can be
+// decrypted, a *biscuit.UndecryptableError describes each failure.
+func decryptAny(ctx context.Context, name string, values store.ValueList) ([]byte, error) {
+	failures := &biscuit.UndecryptableError{Name: name}
+	for _, value := range values {
+		plaintext, err := decryptOneValue(ctx, value, name)
+		if err != nil {
+			failures.Errors = append(failures.Errors, biscuit.NewDecryptError(name, value, err))
+			continue
+		}
+		for _, failure := range failures.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", failure)
+		}
+		return plaintext, nil
 	}
-	return keyPlaintext, nil
+	return nil, failures
+}
+
+func decryptOneValue(ctx context.Context, value store.Value, name string) ([]byte, error) {
+	return envelope.Decrypt(ctx, keymanager.New, value, name)
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type history struct {
+	name     *string
+	filename *string
+}
+
+// NewHistory configures the command to list the versions of a secret.
+func NewHistory(c *kingpin.CmdClause) shared.Command {
+	return &history{
+		name:     shared.SecretNameArg(c),
+		filename: shared.FilenameFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *history) Run(ctx context.Context) error {
+	if err := checkSecretName(*r.name); err != nil {
+		return err
+	}
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entry, err := database.GetEntry(*r.name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tUPDATED\tUPDATED BY\tDESCRIPTION")
+	printRevision(w, strconv.Itoa(entry.Version())+" (current)", entry.Metadata)
+	for _, revision := range entry.History {
+		version := "-"
+		if revision.Metadata != nil {
+			version = strconv.Itoa(revision.Metadata.Version)
+		}
+		printRevision(w, version, revision.Metadata)
+	}
+	return w.Flush()
+}
+
+func printRevision(w *tabwriter.Writer, version string, metadata *store.Metadata) {
+	if metadata == nil {
+		metadata = &store.Metadata{}
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", version, formatTime(metadata.UpdatedAt),
+		orDash(metadata.UpdatedBy), metadata.Description)
+}
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/identity"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+type importCmd struct {
+	filename     *string
+	lockTimeout  *time.Duration
+	format       *string
+	source       *string
+	overwrite    *bool
+	skipExisting *bool
+	dryRun       *bool
+}
+
+// NewImport configures the command to encrypt secrets read from another format.
+func NewImport(c *kingpin.CmdClause) shared.Command {
+	params := &importCmd{}
+	params.filename = shared.FilenameFlag(c)
+	params.lockTimeout = shared.LockTimeoutFlag(c)
+	params.format = c.Flag("format", "Format of the file being imported.").
+		Default(exportYAML).
+		Enum(exportDotenv, exportJSON, exportYAML, exportK8sSecret)
+	params.source = c.Arg("file", "File to import secrets from. If omitted, secrets are read from "+
+		"stdin.").String()
+	params.overwrite = c.Flag("overwrite", "Replace secrets that already exist. The previous "+
+		"values are kept in the history.").Bool()
+	params.skipExisting = c.Flag("skip-existing", "Leave secrets that already exist unchanged.").
+		Bool()
+	params.dryRun = c.Flag("dry-run", "Show the changes that would be made without making them.").
+		Short('n').
+		Bool()
+	return params
+}
+
+// Run runs the command.
+func (r *importCmd) Run(ctx context.Context) error {
+	if *r.overwrite && *r.skipExisting {
+		return errors.New("--overwrite and --skip-existing cannot be used together")
+	}
+	contents, err := r.read()
+	if err != nil {
+		return err
+	}
+	secrets, err := parseImport(*r.format, contents)
+	if err != nil && len(*r.source) == 0 {
+		return fmt.Errorf("stdin: %w", err)
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", *r.source, err)
+	}
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var names []string
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var added, overwritten, skipped, conflicts []string
+	for _, name := range names {
+		if name == store.KeyTemplateName {
+			return fmt.Errorf("refusing to import %s", store.KeyTemplateName)
+		}
+		if _, present := entries[name]; !present {
+			added = append(added, name)
+			continue
+		}
+		switch {
+		case *r.overwrite:
+			overwritten = append(overwritten, name)
+		case *r.skipExisting:
+			skipped = append(skipped, name)
+		default:
+			conflicts = append(conflicts, name)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("already present: %s; use --overwrite to replace or --skip-existing to "+
+			"leave unchanged", strings.Join(conflicts, ", "))
+	}
+	for _, name := range added {
+		fmt.Printf("+ %s\n", name)
+	}
+	for _, name := range overwritten {
+		fmt.Printf("~ %s\n", name)
+	}
+	for _, name := range skipped {
+		fmt.Printf("  %s (exists; skipped)\n", name)
+	}
+	fmt.Printf("%d to add, %d to overwrite, %d skipped.\n", len(added), len(overwritten), len(skipped))
+	if *r.dryRun || len(added)+len(overwritten) == 0 {
+		return nil
+	}
+
+	keys, err := database.GetKeyIds()
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w; create it with kms init or passphrase init first", err)
+	} else if err != nil {
+		return err
+	}
+	if err := checkPassphraseKeys(keys); err != nil {
+		return err
+	}
+	encrypted := make(map[string]store.ValueList)
+	for _, name := range append(added, overwritten...) {
+		if encrypted[name], err = encryptAll(ctx, keys, name, []byte(secrets[name])); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	updatedBy := identity.Caller(ctx, keys)
+	return database.Update(func(current store.EntryMap) error {
+		for name := range encrypted {
+			if !reflect.DeepEqual(current[name], entries[name]) {
+				return fmt.Errorf("%s was modified by another process; no changes were saved", name)
+			}
+		}
+		for name, values := range encrypted {
+			entry := current[name]
+			entry.SupersedeBy(values, current.HistoryLimit(), updatedBy)
+			current[name] = entry
+		}
+		return nil
+	})
+}
+
+func (r *importCmd) read() ([]byte, error) {
+	if len(*r.source) == 0 {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(*r.source)
+}
+
+// parseImport returns the secrets in contents, keyed by name.
+func parseImport(format string, contents []byte) (map[string]string, error) {
+	switch format {
+	case exportDotenv:
+		return parseDotenv(contents)
+	case exportJSON:
+		var parsed map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.UseNumber()
+		if err := decoder.Decode(&parsed); err != nil {
+			return nil, err
+		}
+		secrets := make(map[string]string)
+		for name, value := range parsed {
+			switch v := value.(type) {
+			case string:
+				secrets[name] = v
+			case json.Number:
+				secrets[name] = v.String()
+			case bool:
+				secrets[name] = fmt.Sprint(v)
+			default:
+				return nil, fmt.Errorf("%s: values must be strings, numbers, or booleans", name)
+			}
+		}
+		return secrets, nil
+	case exportK8sSecret:
+		var manifest struct {
+			Kind       string            `yaml:"kind"`
+			Data       map[string]string `yaml:"data"`
+			StringData map[string]string `yaml:"stringData"`
+		}
+		if err := yaml.Unmarshal(contents, &manifest); err != nil {
+			return nil, err
+		}
+		if manifest.Kind != "Secret" {
+			return nil, fmt.Errorf("expected a manifest of kind Secret, not '%s'", manifest.Kind)
+		}
+		secrets := make(map[string]string)
+		for name, encoded := range manifest.Data {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			secrets[name] = string(decoded)
+		}
+		// As in Kubernetes, stringData takes precedence over data.
+		for name, value := range manifest.StringData {
+			secrets[name] = value
+		}
+		return secrets, nil
+	default:
+		secrets := make(map[string]string)
+		return secrets, yaml.Unmarshal(contents, &secrets)
+	}
+}
+
+// parseDotenv parses NAME=VALUE lines, optionally preceded by "export". Values may be unquoted,
+// single-quoted (read literally), or double-quoted (with backslash escapes). Blank lines and lines
+// starting with # are ignored, as is a # following whitespace after an unquoted value. Only a
+// comment may follow a quoted value.
+func parseDotenv(contents []byte) (map[string]string, error) {
+	secrets := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	// Certificates and other long values can exceed the default 64 KiB line limit; no line can be
+	// longer than the whole file.
+	scanner.Buffer(nil, len(contents)+1)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		equals := strings.Index(line, "=")
+		if equals < 1 {
+			return nil, fmt.Errorf("line %d: expected NAME=VALUE", lineNumber)
+		}
+		name := strings.TrimSpace(line[:equals])
+		value, err := parseDotenvValue(strings.TrimSpace(line[equals+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		secrets[name] = value
+	}
+	return secrets, scanner.Err()
+}
+
+// checkAfterQuote returns an error unless rest, the text after the closing quote of a value, is
+// empty or a comment.
+func checkAfterQuote(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if len(rest) > 0 && !strings.HasPrefix(rest, "#") {
+		return errors.New("unexpected text after the closing quote")
+	}
+	return nil
+}
+
+func parseDotenvValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", errors.New("unterminated single-quoted value")
+		}
+		return raw[1 : end+1], checkAfterQuote(raw[end+2:])
+	case strings.HasPrefix(raw, `"`):
+		var value strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch raw[i] {
+			case '"':
+				return value.String(), checkAfterQuote(raw[i+1:])
+			case '\\':
+				i++
+				if i == len(raw) {
+					return "", errors.New("unterminated double-quoted value")
+				}
+				switch raw[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 'r':
+					value.WriteByte('\r')
+				case 't':
+					value.WriteByte('\t')
+				default:
+					value.WriteByte(raw[i])
+				}
+			default:
+				value.WriteByte(raw[i])
+			}
+		}
+		return "", errors.New("unterminated double-quoted value")
+	default:
+		if comment := strings.Index(raw, " #"); comment >= 0 {
+			raw = raw[:comment]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+}
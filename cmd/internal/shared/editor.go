@@ -0,0 +1,34 @@
+package shared
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errNoEditorFound = errors.New("Set your editor preference with VISUAL or EDITOR environment variables.")
+
+// FindEditor returns the user's preferred editor.
+func FindEditor() (string, error) {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		candidate := os.Getenv(name)
+		if len(candidate) > 0 {
+			return candidate, nil
+		}
+	}
+	return "", errNoEditorFound
+}
+
+// RunEditor opens filename in the user's preferred editor and waits for it to exit.
+func RunEditor(filename string) error {
+	editor, err := FindEditor()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(editor, filename)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -3,12 +3,17 @@ package shared
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/dcoker/biscuit/algorithms"
 	"github.com/dcoker/biscuit/algorithms/secretbox"
+	"github.com/dcoker/biscuit/store"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -142,6 +147,44 @@ func StringFlag(s kingpin.Settings, sv *StringValue) *string {
 	return &sv.v
 }
 
+// DurationValue is a flag.Value for durations that, in addition to the units accepted by
+// time.ParseDuration, accepts whole days (ex: 90d) and weeks (ex: 2w).
+type DurationValue struct {
+	v time.Duration
+}
+
+// Set is called by the flag parser.
+func (d *DurationValue) Set(input string) error {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(input, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(input, suffix))
+			if err != nil {
+				return fmt.Errorf("invalid duration '%s'", input)
+			}
+			d.v = time.Duration(n) * unit
+			return nil
+		}
+	}
+	v, err := time.ParseDuration(input)
+	if err != nil {
+		return err
+	}
+	d.v = v
+	return nil
+}
+
+// String returns the current flag value.
+func (d *DurationValue) String() string {
+	return d.v.String()
+}
+
+// DurationFlag sets a DurationValue as the target value for a Kingpin flag.
+func DurationFlag(s kingpin.Settings) *time.Duration {
+	dv := &DurationValue{}
+	s.SetValue(dv)
+	return &dv.v
+}
+
 // AlgorithmFlag defines a flag for the algorithm
 func AlgorithmFlag(cc *kingpin.CmdClause) *string {
 	return cc.Flag("algorithm", "Encryption algorithm. If the environment variable BISCUIT_ALGORITHM is "+
@@ -155,8 +198,10 @@ func AlgorithmFlag(cc *kingpin.CmdClause) *string {
 
 // FilenameFlag defines a flag for the filename.
 func FilenameFlag(cc *kingpin.CmdClause) *string {
-	return cc.Flag("filename", "Name of file storing the secrets. If the environment variable BISCUIT_FILENAME "+
-		"is set, it will be used as the default value.").
+	return cc.Flag("filename", "Name of file storing the secrets, or the URL of a store of the form "+
+		"SCHEME://PATH (schemes: "+strings.Join(store.GetSchemes(), ", ")+"). To use SSM "+
+		"Parameter Store, see biscuit sync ssm. If the environment variable BISCUIT_FILENAME is "+
+		"set, it will be used as the default value.").
 		PlaceHolder("FILE").
 		Envar("BISCUIT_FILENAME").
 		Short('f').
@@ -164,6 +209,35 @@ func FilenameFlag(cc *kingpin.CmdClause) *string {
 		String()
 }
 
+// LockTimeoutFlag defines a flag for how long to wait for the lock on the secrets file.
+func LockTimeoutFlag(cc *kingpin.CmdClause) *time.Duration {
+	return cc.Flag("lock-timeout", "How long to wait for other biscuit processes to finish writing "+
+		"to FILE. If the environment variable BISCUIT_LOCK_TIMEOUT is set, it will be used as the "+
+		"default value.").
+		PlaceHolder("DURATION").
+		Envar("BISCUIT_LOCK_TIMEOUT").
+		Default(store.DefaultLockTimeout.String()).
+		Duration()
+}
+
+// AgentSocketFlag defines a flag for the path of the Unix socket of the agent.
+func AgentSocketFlag(cc *kingpin.CmdClause) *string {
+	return cc.Flag("socket", "Path of the Unix socket of biscuit agent. If the environment variable "+
+		"BISCUIT_AGENT_SOCKET is set, it will be used as the default value.").
+		PlaceHolder("PATH").
+		Envar("BISCUIT_AGENT_SOCKET").
+		Default(defaultAgentSocket()).
+		String()
+}
+
+// defaultAgentSocket returns a per-user socket path, preferring $XDG_RUNTIME_DIR.
+func defaultAgentSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		return filepath.Join(dir, "biscuit", "agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("biscuit-%d", os.Getuid()), "agent.sock")
+}
+
 // AwsRegionPriority defines a flag allowing the user to specify an ordered list of
 // AWS regions to prioritize.
 func AwsRegionPriorityFlag(cc *kingpin.CmdClause) *[]string {
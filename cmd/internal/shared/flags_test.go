@@ -0,0 +1,42 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		invalid  bool
+	}{
+		{input: "90d", expected: 90 * 24 * time.Hour},
+		{input: "0d", expected: 0},
+		{input: "2w", expected: 14 * 24 * time.Hour},
+		{input: "36h", expected: 36 * time.Hour},
+		{input: "1h30m", expected: 90 * time.Minute},
+		{input: "0s", expected: 0},
+		{input: "1.5d", invalid: true},
+		{input: "d", invalid: true},
+		{input: "w", invalid: true},
+		{input: "3x", invalid: true},
+		{input: "90", invalid: true},
+		{input: "", invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var d DurationValue
+			err := d.Set(test.input)
+			if test.invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, d.v)
+			assert.Equal(t, test.expected.String(), d.String())
+		})
+	}
+}
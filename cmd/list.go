@@ -3,6 +3,11 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/dcoker/biscuit/cmd/internal/shared"
 	"github.com/dcoker/biscuit/store"
@@ -11,26 +16,77 @@ import (
 
 type list struct {
 	filename *string
+	long     *bool
 }
 
 // NewList configures the command to list secrets.
 func NewList(c *kingpin.CmdClause) shared.Command {
-	return &list{filename: shared.FilenameFlag(c)}
+	return &list{
+		filename: shared.FilenameFlag(c),
+		long: c.Flag("long", "Show the metadata of each secret: when it was last updated and by "+
+			"whom, its owner, tags, and description.").Short('l').Bool(),
+	}
 }
 
 // Run runs the command.
 func (r *list) Run(ctx context.Context) error {
-	database := store.NewFileStore(*r.filename)
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
 
 	entries, err := database.GetAll()
 	if err != nil {
 		return err
 	}
+	var names []string
 	for name := range entries {
 		if name == store.KeyTemplateName {
 			continue
 		}
-		fmt.Printf("%s\n", name)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if !*r.long {
+		for _, name := range names {
+			fmt.Printf("%s\n", name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUPDATED\tUPDATED BY\tOWNER\tTAGS\tDESCRIPTION")
+	for _, name := range names {
+		metadata := entries[name].Metadata
+		if metadata == nil {
+			metadata = &store.Metadata{}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, formatTime(metadata.UpdatedAt),
+			orDash(metadata.UpdatedBy), orDash(metadata.Owner), orDash(formatTags(metadata.Tags)),
+			metadata.Description)
+	}
+	return w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatTags(tags map[string]string) string {
+	var pairs []string
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
 	}
-	return nil
+	return s
 }
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type migrate struct {
+	filename    *string
+	to          *string
+	lockTimeout *time.Duration
+}
+
+// NewMigrate configures the command to copy secrets to another store.
+func NewMigrate(c *kingpin.CmdClause) shared.Command {
+	return &migrate{
+		filename: shared.FilenameFlag(c),
+		to: c.Flag("to", "Store to copy the secrets to (ex: dir://secrets/). It must not contain "+
+			"any secrets.").
+			PlaceHolder("URL").
+			Required().
+			String(),
+		lockTimeout: shared.LockTimeoutFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *migrate) Run(ctx context.Context) error {
+	source, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	destination, err := store.Open(*r.to, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	entries, err := source.GetAll()
+	if err != nil {
+		return err
+	}
+
+	// Entries are copied verbatim, including metadata and history. Ciphertexts are bound to the
+	// secret names, not to the store, so nothing needs to be decrypted.
+	err = destination.Update(func(existing store.EntryMap) error {
+		if len(existing) > 0 {
+			return fmt.Errorf("%s already contains secrets; refusing to overwrite them", *r.to)
+		}
+		for name, entry := range entries {
+			existing[name] = entry
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	count := len(entries)
+	if _, present := entries[store.KeyTemplateName]; present {
+		count--
+	}
+	fmt.Printf("Copied %d %s to %s. %s was not modified.\n", count,
+		stringsFunc.Pluralize("secret", count), *r.to, *r.filename)
+	return nil
+}
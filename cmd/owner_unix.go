@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkOwner returns an error if the file described by info is not owned by the current user, or
+// by root if allowRoot is set.
+func checkOwner(path string, info os.FileInfo, allowRoot bool) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("could not determine the owner of %s", path)
+	}
+	if int(stat.Uid) != os.Getuid() && !(allowRoot && stat.Uid == 0) {
+		return fmt.Errorf("%s is owned by user %d, not by the current user", path, stat.Uid)
+	}
+	return nil
+}
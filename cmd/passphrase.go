@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type passphraseInit struct {
+	kdf,
+	kdfParams,
+	algorithm,
+	filename *string
+	lockTimeout *time.Duration
+}
+
+// NewPassphraseInit configures the command to add a passphrase key to the template.
+func NewPassphraseInit(c *kingpin.CmdClause) shared.Command {
+	return &passphraseInit{
+		kdf: c.Flag("kdf", "Key derivation function used to turn the passphrase into a key.").
+			Default(keymanager.ScryptKdf).
+			Enum(keymanager.ScryptKdf, keymanager.Argon2idKdf),
+		kdfParams: c.Flag("kdf-params", "Cost parameters for the key derivation function. "+
+			"Ex: N=32768,r=8,p=1 for scrypt, or t=3,m=65536,p=4 for argon2id. Defaults to "+
+			"the values in these examples.").
+			PlaceHolder("PARAMS").
+			String(),
+		algorithm:   shared.AlgorithmFlag(c),
+		filename:    shared.FilenameFlag(c),
+		lockTimeout: shared.LockTimeoutFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *passphraseInit) Run(ctx context.Context) error {
+	keyID, err := resolvePassphraseKeyID(ctx, *r.kdf+":"+*r.kdfParams)
+	if err != nil {
+		return err
+	}
+
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	err = database.Update(func(entries store.EntryMap) error {
+		// The new key replaces any existing passphrase key. Secrets written under the old key
+		// remain readable, since their values record the key that they were encrypted under.
+		template := entries[store.KeyTemplateName]
+		var values store.ValueList
+		for _, value := range template.Values {
+			if value.KeyManager != keymanager.PassphraseLabel {
+				values = append(values, value)
+			}
+		}
+		template.Values = append(values, store.Value{
+			Key: store.Key{
+				KeyID:      keyID,
+				KeyManager: keymanager.PassphraseLabel,
+				Algorithm:  *r.algorithm,
+			},
+		})
+		entries[store.KeyTemplateName] = template
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("The template used by %s has been updated to use a %s passphrase key.\n",
+		*r.filename, *r.kdf)
+	return nil
+}
+
+// checkPassphraseKeys returns an error if any of the passphrase keys in a template lack a salt or
+// check value. Writing under such a key would generate a new salt each time, and would accept a
+// mistyped passphrase.
+func checkPassphraseKeys(keys []store.Key) error {
+	for _, key := range keys {
+		if key.KeyManager == keymanager.PassphraseLabel &&
+			keymanager.MatchPassphraseKeyID(key.KeyID, nil) == "" {
+			return fmt.Errorf("the template's passphrase key %s has no salt or check value; "+
+				"replace it with biscuit passphrase init", key.KeyID)
+		}
+	}
+	return nil
+}
+
+// resolvePassphraseKeyID returns keyID with a fresh salt and the check value of the passphrase
+// recorded in it, reading the passphrase if necessary.
+func resolvePassphraseKeyID(ctx context.Context, keyID string) (string, error) {
+	keyManager, err := keymanager.New(keymanager.PassphraseLabel)
+	if err != nil {
+		return "", err
+	}
+	// Generating a throwaway envelope key resolves the salt and check value into the key ID.
+	envelopeKey, err := keyManager.GenerateEnvelopeKey(ctx, keyID, "")
+	if err != nil {
+		return "", err
+	}
+	return envelopeKey.ResolvedID, nil
+}
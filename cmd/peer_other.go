@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package cmd
+
+import (
+	"errors"
+	"net"
+)
+
+// peerUID returns the user ID of the process on the other end of a Unix socket connection.
+func peerUID(conn net.Conn) (int, error) {
+	return 0, errors.New("peer credentials are not supported on this platform")
+}
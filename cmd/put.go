@@ -2,17 +2,20 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"strconv"
 	"strings"
-
 	"sync"
+	"time"
 
 	"github.com/dcoker/biscuit/algorithms"
 	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/envelope"
+	"github.com/dcoker/biscuit/internal/identity"
 	"github.com/dcoker/biscuit/keymanager"
 	"github.com/dcoker/biscuit/store"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -20,13 +23,18 @@ import (
 
 // Put implements the "put" command.
 type put struct {
-	keyID      *string
-	keyManager *string
-	name       *string
-	fromFile   **os.File
-	value      *string
-	algo       *string
-	filename   *string
+	keyID        *string
+	keyManager   *string
+	name         *string
+	fromFile     **os.File
+	value        *string
+	algo         *string
+	filename     *string
+	lockTimeout  *time.Duration
+	description  *string
+	owner        *string
+	tags         *map[string]string
+	historyLimit *string
 }
 
 var (
@@ -54,6 +62,17 @@ func NewPut(c *kingpin.CmdClause) shared.Command {
 		"of the command line.").PlaceHolder("FILE").Short('i').File()
 	write.algo = shared.AlgorithmFlag(c)
 	write.filename = shared.FilenameFlag(c)
+	write.lockTimeout = shared.LockTimeoutFlag(c)
+	write.description = c.Flag("description", "Description of the secret, stored unencrypted. If "+
+		"not set, the existing description is kept.").String()
+	write.owner = c.Flag("owner", "Owner of the secret (ex: a team or email address), stored "+
+		"unencrypted. If not set, the existing owner is kept.").String()
+	write.tags = c.Flag("tag", "Tag to set on the secret, stored unencrypted. May be repeated. An "+
+		"empty VALUE removes the tag.").PlaceHolder("KEY=VALUE").StringMap()
+	write.historyLimit = c.Flag("history-limit", "Keep N previous versions of each secret from now "+
+		"on (0 disables history). The limit is stored in the "+store.KeyTemplateName+" template and "+
+		"applies to every secret in FILE. If not set, the existing limit is kept.").
+		PlaceHolder("N").String()
 
 	return write
 }
@@ -65,9 +84,15 @@ type encryptResult struct {
 
 // Run runs the command.
 func (w *put) Run(ctx context.Context) error {
-	database := store.NewFileStore(*w.filename)
+	if err := checkSecretName(*w.name); err != nil {
+		return err
+	}
+	database, err := store.Open(*w.filename, store.LockTimeout(*w.lockTimeout))
+	if err != nil {
+		return err
+	}
 
-	keys, err := w.chooseKeys(database)
+	keys, err := w.chooseKeys(ctx, database)
 	if err != nil {
 		return err
 	}
@@ -77,43 +102,77 @@ func (w *put) Run(ctx context.Context) error {
 		return err
 	}
 
-	results := make(chan encryptResult, len(keys))
-	var wg sync.WaitGroup
-	for _, keyConfig := range keys {
-		wg.Add(1)
-		go func(keyConfig store.Key, plaintext []byte) {
-			defer wg.Done()
-			value, err := encryptOne(ctx, keyConfig, *w.name, plaintext)
-			results <- encryptResult{value, err}
-		}(keyConfig, plaintext)
+	historyLimit, err := w.parseHistoryLimit()
+	if err != nil {
+		return err
 	}
-	wg.Wait()
-	close(results)
 
-	var valueList []store.Value
-	for value := range results {
-		if value.err != nil {
-			return value.err
-		}
-		valueList = append(valueList, value.value)
+	valueList, err := encryptAll(ctx, keys, *w.name, plaintext)
+	if err != nil {
+		return err
 	}
 
-	// If the file doesn't have a template, create one from the keys used here.
-	if _, err := database.Get(store.KeyTemplateName); errors.Is(err, fs.ErrNotExist) {
-		var values []store.Value
-		for _, key := range keys {
-			values = append(values, store.Value{Key: key})
+	// If the file doesn't exist yet, create a template from the keys used here.
+	_, err = database.GetAll()
+	newStore := errors.Is(err, fs.ErrNotExist)
+	updatedBy := identity.Caller(ctx, keys)
+	return database.Update(func(entries store.EntryMap) error {
+		if newStore && len(entries) == 0 {
+			var values []store.Value
+			for _, key := range keys {
+				values = append(values, store.Value{Key: key})
+			}
+			entries[store.KeyTemplateName] = store.Entry{Values: values}
 		}
-		err := database.Put(store.KeyTemplateName, values)
-		if err != nil {
-			return err
+		if historyLimit != nil {
+			template, present := entries[store.KeyTemplateName]
+			if !present {
+				return fmt.Errorf("--history-limit is stored in the %s template, which %s does not have",
+					store.KeyTemplateName, *w.filename)
+			}
+			template.HistoryLimit = historyLimit
+			entries[store.KeyTemplateName] = template
 		}
+		entry := entries[*w.name]
+		entry.SupersedeBy(valueList, entries.HistoryLimit(), updatedBy)
+		w.applyMetadataFlags(entry.Metadata)
+		entries[*w.name] = entry
+		return nil
+	})
+}
+
+// parseHistoryLimit returns the value of --history-limit, or nil if it was not set.
+func (w *put) parseHistoryLimit() (*int, error) {
+	if len(*w.historyLimit) == 0 {
+		return nil, nil
+	}
+	limit, err := strconv.Atoi(*w.historyLimit)
+	if err != nil || limit < 0 {
+		return nil, fmt.Errorf("--history-limit must be a number of versions, not '%s'", *w.historyLimit)
 	}
+	return &limit, nil
+}
 
-	return database.Put(*w.name, valueList)
+func (w *put) applyMetadataFlags(metadata *store.Metadata) {
+	if len(*w.description) > 0 {
+		metadata.Description = *w.description
+	}
+	if len(*w.owner) > 0 {
+		metadata.Owner = *w.owner
+	}
+	for key, value := range *w.tags {
+		if len(value) == 0 {
+			delete(metadata.Tags, key)
+			continue
+		}
+		if metadata.Tags == nil {
+			metadata.Tags = make(map[string]string)
+		}
+		metadata.Tags[key] = value
+	}
 }
 
-func (w *put) chooseKeys(database store.FileStore) ([]store.Key, error) {
+func (w *put) chooseKeys(ctx context.Context, database store.Store) ([]store.Key, error) {
 	if len(*w.keyID) > 0 {
 		var keys []store.Key
 		split := strings.Split(*w.keyID, ",")
@@ -123,7 +182,7 @@ func (w *put) chooseKeys(database store.FileStore) ([]store.Key, error) {
 				KeyID:      key,
 				Algorithm:  *w.algo})
 		}
-		return keys, nil
+		return resolvePassphraseKeys(ctx, database, keys)
 	}
 	algo, err := algorithms.Get(*w.algo)
 	if err != nil {
@@ -133,13 +192,51 @@ func (w *put) chooseKeys(database store.FileStore) ([]store.Key, error) {
 		return []store.Key{{Algorithm: *w.algo}}, nil
 	}
 	templateKeys, err := database.GetKeyIds()
-	if os.IsNotExist(err) {
+	if errors.Is(err, fs.ErrNotExist) {
 		return nil, errFileDoesNotExist
 	}
+	if errors.Is(err, store.ErrNoTemplate) {
+		return nil, fmt.Errorf("%w; specify a key ID with --key-id, or add a template", err)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return templateKeys, nil
+	return templateKeys, checkPassphraseKeys(templateKeys)
+}
+
+// resolvePassphraseKeys completes the passphrase key IDs in keys, so that every write under a
+// passphrase key uses the same salt and detects a mistyped passphrase. Key IDs without a salt and
+// check value are matched against the template. They are only resolved afresh for a new store,
+// whose template then records the result.
+func resolvePassphraseKeys(ctx context.Context, database store.Store, keys []store.Key) ([]store.Key, error) {
+	templateKeys, err := database.GetKeyIds()
+	newStore := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !newStore && !errors.Is(err, store.ErrNoTemplate) {
+		return nil, err
+	}
+	var candidates []string
+	for _, key := range templateKeys {
+		if key.KeyManager == keymanager.PassphraseLabel {
+			candidates = append(candidates, key.KeyID)
+		}
+	}
+	for i, key := range keys {
+		if key.KeyManager != keymanager.PassphraseLabel {
+			continue
+		}
+		if matched := keymanager.MatchPassphraseKeyID(key.KeyID, candidates); matched != "" {
+			keys[i].KeyID = matched
+			continue
+		}
+		if !newStore {
+			return nil, fmt.Errorf("passphrase key %s does not match a passphrase key in the "+
+				"template; add one with biscuit passphrase init", key.KeyID)
+		}
+		if keys[i].KeyID, err = resolvePassphraseKeyID(ctx, key.KeyID); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
 }
 
 func (w *put) choosePlaintext() ([]byte, error) {
@@ -153,33 +250,31 @@ func (w *put) choosePlaintext() ([]byte, error) {
 	return []byte(*w.value), nil
 }
 
-func encryptOne(ctx context.Context, keyConfig store.Key, name string, plaintext []byte) (store.Value, error) {
-	var value store.Value
-	algo, err := algorithms.Get(keyConfig.Algorithm)
-	if err != nil {
-		return value, err
+// encryptAll encrypts plaintext under each of keys concurrently.
+func encryptAll(ctx context.Context, keys []store.Key, name string, plaintext []byte) (store.ValueList, error) {
+	results := make(chan encryptResult, len(keys))
+	var wg sync.WaitGroup
+	for _, keyConfig := range keys {
+		wg.Add(1)
+		go func(keyConfig store.Key, plaintext []byte) {
+			defer wg.Done()
+			value, err := encryptOne(ctx, keyConfig, name, plaintext)
+			results <- encryptResult{value, err}
+		}(keyConfig, plaintext)
 	}
-	value.Algorithm = keyConfig.Algorithm
+	wg.Wait()
+	close(results)
 
-	var envelopeKey keymanager.EnvelopeKey
-	if algo.NeedsKey() {
-		keyManager, err := keymanager.New(keyConfig.KeyManager)
-		if err != nil {
-			return value, err
-		}
-		value.KeyManager = keyManager.Label()
-		envelopeKey, err = keyManager.GenerateEnvelopeKey(ctx, keyConfig.KeyID, name)
-		if err != nil {
-			return value, err
+	var valueList store.ValueList
+	for value := range results {
+		if value.err != nil {
+			return nil, value.err
 		}
-		value.KeyID = envelopeKey.ResolvedID
-		value.KeyCiphertext = base64.StdEncoding.EncodeToString(envelopeKey.Ciphertext)
+		valueList = append(valueList, value.value)
 	}
+	return valueList, nil
+}
 
-	ciphertext, err := algo.Encrypt(envelopeKey.Plaintext, plaintext)
-	if err != nil {
-		return value, err
-	}
-	value.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
-	return value, nil
+func encryptOne(ctx context.Context, keyConfig store.Key, name string, plaintext []byte) (store.Value, error) {
+	return envelope.Encrypt(ctx, keymanager.New, keyConfig, name, plaintext)
 }
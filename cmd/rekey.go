@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type rekey struct {
+	names          *[]string
+	filename       *string
+	algorithm      *string
+	regionPriority *[]string
+	lockTimeout    *time.Duration
+	dryRun         *bool
+}
+
+// NewRekey configures the command to re-encrypt secrets under the current template.
+func NewRekey(c *kingpin.CmdClause) shared.Command {
+	return &rekey{
+		names: c.Arg("name", "Names of the secrets to re-encrypt. Shell-style glob patterns are "+
+			"supported. If omitted, all secrets are re-encrypted.").Strings(),
+		filename: shared.FilenameFlag(c),
+		algorithm: c.Flag("algorithm", "Encryption algorithm to switch to. If not set, the algorithms "+
+			"in the "+store.KeyTemplateName+" template are used. Options: "+
+			strings.Join(algorithms.GetRegisteredAlgorithmsNames(), ", ")).
+			Short('a').
+			Enum(algorithms.GetRegisteredAlgorithmsNames()...),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		lockTimeout:    shared.LockTimeoutFlag(c),
+		dryRun: c.Flag("dry-run", "Show which keys would be added and removed for each secret without "+
+			"writing the file. New data keys are still requested from the key managers in order to "+
+			"resolve key IDs.").
+			Short('n').
+			Bool(),
+	}
+}
+
+// Run runs the command.
+func (r *rekey) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	keys, err := r.chooseKeys(database)
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.names)
+	if err != nil {
+		return err
+	}
+
+	rekeyed := make(store.EntryMap)
+	for _, name := range names {
+		values := make(store.ValueList, len(entries[name].Values))
+		copy(values, entries[name].Values)
+		store.SortByKmsRegion(*r.regionPriority)(values)
+		plaintext, err := decryptAny(ctx, name, values)
+		if err != nil {
+			return err
+		}
+		entry := entries[name]
+		entry.Values, err = encryptAll(ctx, keys, name, plaintext)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		// Previous versions are re-encrypted too, so that removing a key from the template
+		// revokes access to all versions of the secret.
+		entry.History, err = r.rekeyHistory(ctx, keys, name, entry.History)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		rekeyed[name] = entry
+		printKeyChanges(name, entries[name].Values, entry.Values)
+	}
+
+	if *r.dryRun {
+		fmt.Printf("Dry run: %d %s would be re-encrypted.\n", len(rekeyed),
+			stringsFunc.Pluralize("secret", len(rekeyed)))
+		return nil
+	}
+	if err := replaceEntries(database, entries, rekeyed); err != nil {
+		return err
+	}
+	fmt.Printf("Re-encrypted %d %s.\n", len(rekeyed), stringsFunc.Pluralize("secret", len(rekeyed)))
+	return nil
+}
+
+func (r *rekey) rekeyHistory(ctx context.Context, keys []store.Key, name string, history []store.Revision) ([]store.Revision, error) {
+	var rekeyed []store.Revision
+	for i, revision := range history {
+		values := make(store.ValueList, len(revision.Values))
+		copy(values, revision.Values)
+		store.SortByKmsRegion(*r.regionPriority)(values)
+		plaintext, err := decryptAny(ctx, name, values)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", revisionName(revision, i), err)
+		}
+		revision.Values, err = encryptAll(ctx, keys, name, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		rekeyed = append(rekeyed, revision)
+	}
+	return rekeyed, nil
+}
+
+// revisionName identifies the revision at index in a secret's history. Revisions without a
+// recorded version, such as those added by hand, are identified by their position instead.
+func revisionName(revision store.Revision, index int) string {
+	if revision.Metadata != nil && revision.Metadata.Version > 0 {
+		return fmt.Sprintf("version %d", revision.Metadata.Version)
+	}
+	return fmt.Sprintf("history entry %d", index+1)
+}
+
+func (r *rekey) chooseKeys(database store.Store) ([]store.Key, error) {
+	if len(*r.algorithm) > 0 {
+		algo, err := algorithms.Get(*r.algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if !algo.NeedsKey() {
+			return []store.Key{{Algorithm: *r.algorithm}}, nil
+		}
+	}
+	keys, err := database.GetKeyIds()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPassphraseKeys(keys); err != nil {
+		return nil, err
+	}
+	if len(*r.algorithm) > 0 {
+		for i := range keys {
+			keys[i].Algorithm = *r.algorithm
+		}
+	}
+	return keys, nil
+}
+
+// selectNames returns the entries matching patterns, or all entries except the template if no
+// patterns are given.
+func selectNames(entries store.EntryMap, patterns []string) ([]string, error) {
+	if len(patterns) > 0 {
+		return matchNames(entries, patterns, false)
+	}
+	var names []string
+	for name := range entries {
+		if name == store.KeyTemplateName {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// replaceEntries writes updated to the database in a single locked write, failing if any of the
+// entries were modified since original was read.
+func replaceEntries(database store.Store, original, updated store.EntryMap) error {
+	return database.Update(func(entries store.EntryMap) error {
+		for name := range updated {
+			if !reflect.DeepEqual(entries[name], original[name]) {
+				return fmt.Errorf("%s was modified by another process; no changes were saved", name)
+			}
+		}
+		for name, entry := range updated {
+			entries[name] = entry
+		}
+		return nil
+	})
+}
+
+// printKeyChanges prints the keys that are present in after but not before (+), and vice versa
+// (-).
+func printKeyChanges(name string, before, after store.ValueList) {
+	removed := keySet(before)
+	added := keySet(after)
+	for key := range removed {
+		if _, present := added[key]; present {
+			delete(removed, key)
+			delete(added, key)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Printf("%s: no key changes\n", name)
+		return
+	}
+	fmt.Printf("%s:\n", name)
+	for _, key := range sortedKeys(removed) {
+		fmt.Printf("  - %s\n", key)
+	}
+	for _, key := range sortedKeys(added) {
+		fmt.Printf("  + %s\n", key)
+	}
+}
+
+func keySet(values store.ValueList) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, value := range values {
+		set[describeKey(value.Key)] = struct{}{}
+	}
+	return set
+}
+
+func describeKey(key store.Key) string {
+	if key.KeyManager == "" {
+		return key.Algorithm
+	}
+	return fmt.Sprintf("%s %s (%s)", key.KeyManager, key.KeyID, key.Algorithm)
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/atomicfile"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type render struct {
+	filename       *string
+	regionPriority *[]string
+	template       *string
+	output         *string
+}
+
+// NewRender configures the command to render a template containing secrets.
+func NewRender(c *kingpin.CmdClause) shared.Command {
+	return &render{
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		template: c.Arg("template", "Go text/template to render. Use {{ secret \"NAME\" }} to "+
+			"insert the value of a secret.").
+			Required().
+			ExistingFile(),
+		output: c.Flag("output", "Write to FILE, with permissions 0600, instead of stdout. FILE is "+
+			"only replaced if the template renders successfully.").
+			PlaceHolder("FILE").
+			Short('o').
+			String(),
+	}
+}
+
+// Run runs the command.
+func (r *render) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	// Secrets are only decrypted when the template refers to them, and at most once.
+	cache, err := newSecretCache(database, *r.regionPriority, noExpiry)
+	if err != nil {
+		return err
+	}
+	rendered, err := renderTemplate(*r.template, func(name string) (string, error) {
+		return cache.get(ctx, name)
+	})
+	if err != nil {
+		return err
+	}
+	if len(*r.output) == 0 {
+		_, err := os.Stdout.Write(rendered)
+		return err
+	}
+	return atomicfile.WriteFile(*r.output, rendered, 0600)
+}
+
+// renderTemplate executes the template in filename, using secret to look up the values of
+// secrets.
+func renderTemplate(filename string, secret func(name string) (string, error)) ([]byte, error) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(filename)).
+		Option("missingkey=error").
+		Funcs(renderFuncs(secret)).
+		Parse(string(source))
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, nil); err != nil {
+		return nil, err
+	}
+	return rendered.Bytes(), nil
+}
+
+// renderFuncs returns the functions available to templates.
+func renderFuncs(secret func(name string) (string, error)) template.FuncMap {
+	return template.FuncMap{
+		"secret": secret,
+		"base64": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"base64decode": func(s string) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(s)
+			return string(decoded), err
+		},
+		"json": func(v interface{}) (string, error) {
+			var b bytes.Buffer
+			encoder := json.NewEncoder(&b)
+			encoder.SetEscapeHTML(false)
+			err := encoder.Encode(v)
+			return string(bytes.TrimSuffix(b.Bytes(), []byte("\n"))), err
+		},
+		"quote":      strconv.Quote,
+		"shellquote": shellQuote,
+	}
+}
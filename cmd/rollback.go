@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/identity"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type rollback struct {
+	name           *string
+	to             *int
+	filename       *string
+	regionPriority *[]string
+	lockTimeout    *time.Duration
+}
+
+// NewRollback configures the command to restore a previous version of a secret.
+func NewRollback(c *kingpin.CmdClause) shared.Command {
+	return &rollback{
+		name: shared.SecretNameArg(c),
+		to: c.Flag("to", "Version to restore. See the history command.").
+			PlaceHolder("N").
+			Required().
+			Int(),
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		lockTimeout:    shared.LockTimeoutFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *rollback) Run(ctx context.Context) error {
+	if err := checkSecretName(*r.name); err != nil {
+		return err
+	}
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	original, err := database.GetEntry(*r.name)
+	if err != nil {
+		return err
+	}
+	if *r.to == original.Version() {
+		return fmt.Errorf("version %d of %s is already the current version", *r.to, *r.name)
+	}
+	previous, err := original.Revision(*r.to)
+	if err != nil {
+		return err
+	}
+	values := make(store.ValueList, len(previous))
+	copy(values, previous)
+	store.SortByKmsRegion(*r.regionPriority)(values)
+	plaintext, err := decryptAny(ctx, *r.name, values)
+	if err != nil {
+		return err
+	}
+
+	// The restored version becomes the newest version, encrypted under the keys this secret
+	// currently uses rather than the keys it used at the time.
+	var keys []store.Key
+	for _, value := range original.Values {
+		keys = append(keys, value.Key)
+	}
+	valueList, err := encryptAll(ctx, keys, *r.name, plaintext)
+	if err != nil {
+		return err
+	}
+	updatedBy := identity.Caller(ctx, keys)
+	var version int
+	err = database.Update(func(entries store.EntryMap) error {
+		entry := entries[*r.name]
+		if !reflect.DeepEqual(entry.Values, original.Values) {
+			return fmt.Errorf("%s was modified by another process; no changes were saved", *r.name)
+		}
+		entry.SupersedeBy(valueList, entries.HistoryLimit(), updatedBy)
+		entries[*r.name] = entry
+		version = entry.Version()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored version %d of %s as version %d.\n", *r.to, *r.name, version)
+	return nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type rotate struct {
+	names          *[]string
+	filename       *string
+	olderThan      *time.Duration
+	regionPriority *[]string
+	lockTimeout    *time.Duration
+	dryRun         *bool
+}
+
+// NewRotate configures the command to rotate data keys.
+func NewRotate(c *kingpin.CmdClause) shared.Command {
+	return &rotate{
+		names: c.Arg("name", "Names of the secrets to rotate. Shell-style glob patterns are "+
+			"supported. If omitted, all secrets are rotated.").Strings(),
+		filename: shared.FilenameFlag(c),
+		olderThan: shared.DurationFlag(c.Flag("older-than", "Only rotate data keys generated more "+
+			"than DURATION ago (ex: 90d, 2w, 12h). Values with no recorded rotation time are always "+
+			"rotated.").
+			PlaceHolder("DURATION")),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		lockTimeout:    shared.LockTimeoutFlag(c),
+		dryRun: c.Flag("dry-run", "List the values that would be rotated without changing the file.").
+			Short('n').
+			Bool(),
+	}
+}
+
+// Run runs the command.
+func (r *rotate) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.names)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-*r.olderThan)
+	rotated := make(store.EntryMap)
+	count := 0
+	for _, name := range names {
+		entry := entries[name]
+		values, n, err := r.rotateValues(ctx, name, "", entry.Values, cutoff)
+		if err != nil {
+			return err
+		}
+		count += n
+		changed := n > 0
+		// Previous versions are rotated too, so that no data key older than the cutoff remains
+		// in the file.
+		history := make([]store.Revision, len(entry.History))
+		for i, revision := range entry.History {
+			revision.Values, n, err = r.rotateValues(ctx, name, revisionName(revision, i),
+				revision.Values, cutoff)
+			if err != nil {
+				return err
+			}
+			count += n
+			changed = changed || n > 0
+			history[i] = revision
+		}
+		if !changed || *r.dryRun {
+			continue
+		}
+		entry.Values = values
+		if len(history) > 0 {
+			entry.History = history
+		}
+		rotated[name] = entry
+	}
+
+	if *r.dryRun {
+		fmt.Printf("Dry run: %d %s would be rotated.\n", count, stringsFunc.Pluralize("value", count))
+		return nil
+	}
+	if err := replaceEntries(database, entries, rotated); err != nil {
+		return err
+	}
+	fmt.Printf("Rotated %d %s.\n", count, stringsFunc.Pluralize("value", count))
+	return nil
+}
+
+// rotateValues returns a copy of the values of a secret, or of one of its previous revisions, in
+// which the data keys generated before cutoff are replaced. It also returns the number of values
+// that were, or in a dry run would be, rotated.
+func (r *rotate) rotateValues(ctx context.Context, name, revision string, values store.ValueList,
+	cutoff time.Time) (store.ValueList, int, error) {
+	label := name
+	if len(revision) > 0 {
+		label = fmt.Sprintf("%s (%s)", name, revision)
+	}
+	var stale []int
+	for i, value := range values {
+		if value.KeyManager == "" {
+			continue
+		}
+		if value.RotatedAt == nil || value.RotatedAt.Before(cutoff) {
+			stale = append(stale, i)
+			fmt.Printf("%s: %s\n", label, describeKey(value.Key))
+		}
+	}
+	if len(stale) == 0 || *r.dryRun {
+		return values, len(stale), nil
+	}
+
+	sorted := make(store.ValueList, len(values))
+	copy(sorted, values)
+	store.SortByKmsRegion(*r.regionPriority)(sorted)
+	plaintext, err := decryptAny(ctx, name, sorted)
+	if err != nil && len(revision) > 0 {
+		return nil, 0, fmt.Errorf("%s: %w", revision, err)
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	// Generate fresh data keys under the same key IDs, leaving recently rotated values alone.
+	rotated := make(store.ValueList, len(values))
+	copy(rotated, values)
+	for _, i := range stale {
+		rotated[i], err = encryptOne(ctx, rotated[i].Key, name, plaintext)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	return rotated, len(stale), nil
+}
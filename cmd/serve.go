@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	serveSecretsPath   = "/v1/secrets"
+	serveShutdownGrace = 5 * time.Second
+	// Requests are small GETs, so clients that take longer than this to send one are dropped
+	// rather than allowed to hold connections open.
+	serveReadHeaderTimeout = 5 * time.Second
+	serveReadTimeout       = 10 * time.Second
+	serveIdleTimeout       = 2 * time.Minute
+	// unixListenPrefix marks --listen addresses that are Unix socket paths.
+	unixListenPrefix = "unix:"
+)
+
+type serve struct {
+	filename       *string
+	regionPriority *[]string
+	listen         *string
+	tokenFile      *string
+	allowUIDs      *[]int
+	allow          *[]string
+	ttl            *time.Duration
+	pollInterval   *time.Duration
+
+	cache *secretCache
+	token []byte
+
+	mu        sync.Mutex
+	reloadErr error
+}
+
+// NewServe configures the command to serve decrypted secrets over HTTP.
+func NewServe(c *kingpin.CmdClause) shared.Command {
+	params := &serve{}
+	params.filename = shared.FilenameFlag(c)
+	params.regionPriority = shared.AwsRegionPriorityFlag(c)
+	params.listen = c.Flag("listen", "Address to listen on, such as 127.0.0.1:8080, or unix:PATH "+
+		"to listen on a Unix socket.").
+		PlaceHolder("ADDRESS").
+		Required().
+		String()
+	params.tokenFile = c.Flag("token-file", "Require requests to present the contents of FILE as "+
+		"a bearer token (Authorization: Bearer TOKEN).").
+		PlaceHolder("FILE").
+		String()
+	params.allowUIDs = c.Flag("allow-uid", "Allow requests from processes running as UID, without "+
+		"a token. Requires a unix: address, and is only supported on Linux. May be repeated.").
+		PlaceHolder("UID").
+		Ints()
+	allowFlag := c.Flag("allow", "Comma-delimited list of secret names or glob patterns that may be "+
+		"read. By default, all secrets may be read.").PlaceHolder("NAME,...")
+	allow := (&shared.CommaSeparatedList{}).Name("allow")
+	allowFlag.SetValue(allow)
+	params.allow = &allow.V
+	params.ttl = c.Flag("ttl", "How long to keep decrypted secrets in memory. 0 disables caching.").
+		Default("5m").
+		Duration()
+	params.pollInterval = c.Flag("poll-interval", "How often to check stores that are not on the "+
+		"local filesystem for changes. Local stores are watched for changes instead. 0 disables "+
+		"polling.").
+		Default("1m").
+		Duration()
+	return params
+}
+
+// Run runs the command.
+func (r *serve) Run(ctx context.Context) error {
+	unixSocket := strings.HasPrefix(*r.listen, unixListenPrefix)
+	if len(*r.tokenFile) == 0 && len(*r.allowUIDs) == 0 {
+		return errors.New("specify --token-file, --allow-uid, or both to authenticate requests")
+	}
+	if len(*r.allowUIDs) > 0 && !unixSocket {
+		return fmt.Errorf("--allow-uid requires a %sPATH address", unixListenPrefix)
+	}
+	if len(*r.tokenFile) > 0 {
+		token, err := os.ReadFile(*r.tokenFile)
+		if err != nil {
+			return err
+		}
+		r.token = []byte(strings.TrimSpace(string(token)))
+		if len(r.token) == 0 {
+			return fmt.Errorf("%s is empty", *r.tokenFile)
+		}
+	}
+
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	if r.cache, err = newSecretCache(database, *r.regionPriority, *r.ttl); err != nil {
+		return err
+	}
+	var listener net.Listener
+	if unixSocket {
+		// Access is controlled by --allow-uid and --token-file rather than by the permissions of
+		// the socket.
+		listener, err = listenUnix(strings.TrimPrefix(*r.listen, unixListenPrefix), 0666)
+	} else {
+		listener, err = net.Listen("tcp", *r.listen)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		err := store.Watch(ctx, database, *r.pollInterval, func() {
+			err := reloadCache(r.cache)
+			r.mu.Lock()
+			r.reloadErr = err
+			r.mu.Unlock()
+		})
+		if err != nil {
+			log.Printf("No longer watching %s for changes: %s", *r.filename, err)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", r.ready)
+	mux.Handle(serveSecretsPath, r.authenticated(http.HandlerFunc(r.list)))
+	mux.Handle(serveSecretsPath+"/", r.authenticated(http.HandlerFunc(r.get)))
+	server := &http.Server{
+		Handler:           logRequests(mux),
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+		IdleTimeout:       serveIdleTimeout,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			if uid, err := peerUID(conn); err == nil {
+				return context.WithValue(ctx, peerUIDKey{}, uid)
+			}
+			return ctx
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownGrace)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving secrets from %s on %s", *r.filename, *r.listen)
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Printf("Shutting down")
+	return nil
+}
+
+type peerUIDKey struct{}
+
+// authenticated rejects requests that present neither a valid bearer token nor come from an
+// allowed user.
+func (r *serve) authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if uid, ok := req.Context().Value(peerUIDKey{}).(int); ok {
+			for _, allowed := range *r.allowUIDs {
+				if uid == allowed {
+					next.ServeHTTP(w, req)
+					return
+				}
+			}
+		}
+		if len(r.token) > 0 {
+			presented := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(presented), r.token) == 1 {
+				next.ServeHTTP(w, req)
+				return
+			}
+		}
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+// allowed returns true if name may be read according to --allow.
+func (r *serve) allowed(name string) bool {
+	if len(*r.allow) == 0 {
+		return true
+	}
+	ok, _ := matchesAny(*r.allow, name)
+	return ok
+}
+
+func (r *serve) list(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	names := []string{}
+	for _, name := range r.cache.names() {
+		if r.allowed(name) {
+			names = append(names, name)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"names": names})
+}
+
+func (r *serve) get(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, serveSecretsPath+"/")
+	// Secrets that may not be read are indistinguishable from those that do not exist.
+	if !r.allowed(name) {
+		writeJSONError(w, http.StatusNotFound, (&store.NameNotFoundError{Name: name}).Error())
+		return
+	}
+	value, err := r.cache.get(req.Context(), name)
+	if errors.Is(err, store.ErrNameNotFound) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("get: %s", err)
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"name": name, "value": value})
+}
+
+// ready reports whether the store was read successfully the last time that it changed.
+func (r *serve) ready(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	err := r.reloadErr
+	r.mu.Unlock()
+	if err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs the method, path, status, and duration of each request. Response bodies, which
+// may contain secrets, are never logged.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+		peer := req.RemoteAddr
+		if uid, ok := req.Context().Value(peerUIDKey{}).(int); ok {
+			peer = fmt.Sprintf("uid %d", uid)
+		}
+		log.Printf("%s %s %s %d %s", peer, req.Method, req.URL.Path, recorder.status,
+			time.Since(start).Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import "os"
+
+// forwardedSignals are relayed from biscuit to child processes.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// namedSignals are the signals that watch can send to a process after rewriting files. Windows
+// only supports killing processes.
+var namedSignals = map[string]os.Signal{
+	"KILL": os.Kill,
+}
+
+// shellCommand returns the arguments that run command with the system shell.
+func shellCommand(command string) []string {
+	return []string{"cmd", "/C", command}
+}
@@ -0,0 +1,440 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"github.com/dcoker/biscuit/internal/identity"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// ssmDeleteBatchSize is the maximum number of names accepted by DeleteParameters.
+const ssmDeleteBatchSize = 10
+
+// Largest parameter values, in bytes, accepted by the SSM parameter tiers. Intelligent-Tiering
+// uses the advanced tier for values that need it.
+const (
+	ssmStandardValueSize = 4 * 1024
+	ssmAdvancedValueSize = 8 * 1024
+)
+
+var ssmParameterNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_./-]+$`)
+
+// ssmSync holds the flags shared by the push and pull directions.
+type ssmSync struct {
+	filename       *string
+	path           *string
+	only           *[]string
+	encrypted      *bool
+	prune          *bool
+	dryRun         *bool
+	regionPriority *[]string
+	lockTimeout    *time.Duration
+}
+
+func newSsmSync(c *kingpin.CmdClause) ssmSync {
+	params := ssmSync{}
+	params.filename = shared.FilenameFlag(c)
+	params.path = c.Flag("path", "SSM parameter path that secrets are synchronized with (ex: "+
+		"/app/prod). Each secret NAME corresponds to the parameter PATH/NAME.").
+		PlaceHolder("PATH").
+		Required().
+		String()
+	onlyFlag := c.Flag("only", "Comma-delimited list of secret names or glob patterns to "+
+		"synchronize. By default, all secrets are synchronized.").PlaceHolder("NAME,...")
+	only := (&shared.CommaSeparatedList{}).Name("only")
+	onlyFlag.SetValue(only)
+	params.only = &only.V
+	params.encrypted = c.Flag("encrypted", "Synchronize the biscuit-encrypted values rather than "+
+		"the plaintext, so that reading the parameters also requires access to the biscuit keys. "+
+		"Use the same setting for push and pull.").Bool()
+	params.prune = c.Flag("prune", "Delete secrets from the destination that are not present in the "+
+		"source.").Bool()
+	params.dryRun = c.Flag("dry-run", "Show the changes that would be made without making them.").
+		Short('n').
+		Bool()
+	params.regionPriority = shared.AwsRegionPriorityFlag(c)
+	params.lockTimeout = shared.LockTimeoutFlag(c)
+	return params
+}
+
+// parameterName returns the SSM parameter name of a secret.
+func (s *ssmSync) parameterName(name string) (string, error) {
+	parameter := strings.TrimSuffix(*s.path, "/") + "/" + name
+	if !ssmParameterNameRegexp.MatchString(name) || !strings.HasPrefix(parameter, "/") {
+		return "", fmt.Errorf("%s cannot be stored as SSM parameter %s; names may only contain "+
+			"letters, digits, and _.-/", name, parameter)
+	}
+	return parameter, nil
+}
+
+// secretName returns the name of the secret stored in an SSM parameter.
+func (s *ssmSync) secretName(parameter string) string {
+	return strings.TrimPrefix(parameter, strings.TrimSuffix(*s.path, "/")+"/")
+}
+
+// parameters returns the values of the parameters under the path, keyed by secret name, and
+// filtered by --only.
+func (s *ssmSync) parameters(ctx context.Context, client *ssm.Client) (map[string]string, error) {
+	parameters := make(map[string]string)
+	paginator := ssm.NewGetParametersByPathPaginator(client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(strings.TrimSuffix(*s.path, "/")),
+		Recursive:      true,
+		WithDecryption: true,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, parameter := range page.Parameters {
+			parameters[s.secretName(aws.ToString(parameter.Name))] = aws.ToString(parameter.Value)
+		}
+	}
+	for name := range parameters {
+		if !s.selected(name) {
+			delete(parameters, name)
+		}
+	}
+	return parameters, nil
+}
+
+// selected returns true if name matches --only, or --only was not given.
+func (s *ssmSync) selected(name string) bool {
+	if len(*s.only) == 0 {
+		return true
+	}
+	for _, pattern := range *s.only {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// encode returns the representation of an entry stored in SSM.
+func (s *ssmSync) encode(ctx context.Context, name string, entry store.Entry) (string, error) {
+	if *s.encrypted {
+		encoded, err := yaml.Marshal(entry.Values)
+		return string(encoded), err
+	}
+	values := make(store.ValueList, len(entry.Values))
+	copy(values, entry.Values)
+	store.SortByKmsRegion(*s.regionPriority)(values)
+	plaintext, err := decryptAny(ctx, name, values)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// syncPlan describes the differences between a source and a destination.
+type syncPlan struct {
+	added, changed, removed []string
+	unchanged               int
+}
+
+func (p *syncPlan) sort() {
+	sort.Strings(p.added)
+	sort.Strings(p.changed)
+	sort.Strings(p.removed)
+}
+
+func (p *syncPlan) empty() bool {
+	return len(p.added)+len(p.changed)+len(p.removed) == 0
+}
+
+// print writes the plan without revealing any values.
+func (p *syncPlan) print(describe func(name string) string, prune bool) {
+	for _, name := range p.added {
+		fmt.Printf("+ %s\n", describe(name))
+	}
+	for _, name := range p.changed {
+		fmt.Printf("~ %s\n", describe(name))
+	}
+	for _, name := range p.removed {
+		if prune {
+			fmt.Printf("- %s\n", describe(name))
+		} else {
+			fmt.Printf("  %s (not in source; use --prune to delete)\n", describe(name))
+		}
+	}
+	deleted := 0
+	if prune {
+		deleted = len(p.removed)
+	}
+	fmt.Printf("%d to add, %d to change, %d to delete, %d unchanged.\n", len(p.added),
+		len(p.changed), deleted, p.unchanged)
+}
+
+type ssmPush struct {
+	ssmSync
+	kmsKeyID *string
+	tier     *string
+}
+
+// NewSsmPush configures the command to copy secrets into SSM Parameter Store.
+func NewSsmPush(c *kingpin.CmdClause) shared.Command {
+	return &ssmPush{
+		ssmSync: newSsmSync(c),
+		kmsKeyID: c.Flag("kms-key-id", "KMS key that SSM uses to encrypt the SecureString "+
+			"parameters. Defaults to the account's aws/ssm key.").
+			PlaceHolder("KEY-ID").
+			String(),
+		tier: c.Flag("tier", "SSM parameter tier. Standard parameters hold up to 4 KB, and "+
+			"Advanced ones up to 8 KB, which --encrypted values with several keys may need. "+
+			"Defaults to the account's default tier, which is assumed to be Standard.").
+			Enum(string(types.ParameterTierStandard), string(types.ParameterTierAdvanced),
+				string(types.ParameterTierIntelligentTiering)),
+	}
+}
+
+// maxValueSize returns the size of the largest value that the parameters can hold.
+func (r *ssmPush) maxValueSize() int {
+	if *r.tier == "" || *r.tier == string(types.ParameterTierStandard) {
+		return ssmStandardValueSize
+	}
+	return ssmAdvancedValueSize
+}
+
+// Run runs the command.
+func (r *ssmPush) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.only)
+	if err != nil {
+		return err
+	}
+	var tooLarge []string
+	desired := make(map[string]string)
+	for _, name := range names {
+		parameter, err := r.parameterName(name)
+		if err != nil {
+			return err
+		}
+		if desired[name], err = r.encode(ctx, name, entries[name]); err != nil {
+			return err
+		}
+		if size := len(desired[name]); size > r.maxValueSize() {
+			tooLarge = append(tooLarge, fmt.Sprintf("%s (%d bytes)", parameter, size))
+		}
+	}
+	// Check before writing anything, so that a push does not stop partway through.
+	if len(tooLarge) > 0 {
+		hint := "; use --tier Advanced or --tier Intelligent-Tiering"
+		if r.maxValueSize() == ssmAdvancedValueSize {
+			hint = ""
+		}
+		return fmt.Errorf("too large for an SSM parameter of at most %d bytes: %s%s",
+			r.maxValueSize(), strings.Join(tooLarge, ", "), hint)
+	}
+
+	cfg, err := myAWS.NewConfig(ctx)
+	if err != nil {
+		return err
+	}
+	client := ssm.NewFromConfig(cfg)
+	existing, err := r.parameters(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	var plan syncPlan
+	for _, name := range names {
+		current, present := existing[name]
+		switch {
+		case !present:
+			plan.added = append(plan.added, name)
+		case current != desired[name]:
+			plan.changed = append(plan.changed, name)
+		default:
+			plan.unchanged++
+		}
+	}
+	for name := range existing {
+		if _, present := desired[name]; !present {
+			plan.removed = append(plan.removed, name)
+		}
+	}
+	plan.sort()
+	plan.print(func(name string) string {
+		parameter, _ := r.parameterName(name)
+		return parameter
+	}, *r.prune)
+	if *r.dryRun || plan.empty() {
+		return nil
+	}
+
+	for _, name := range append(plan.added, plan.changed...) {
+		parameter, _ := r.parameterName(name)
+		input := &ssm.PutParameterInput{
+			Name:      aws.String(parameter),
+			Value:     aws.String(desired[name]),
+			Type:      types.ParameterTypeSecureString,
+			Overwrite: true,
+		}
+		if len(*r.kmsKeyID) > 0 {
+			input.KeyId = r.kmsKeyID
+		}
+		if len(*r.tier) > 0 {
+			input.Tier = types.ParameterTier(*r.tier)
+		}
+		if _, err := client.PutParameter(ctx, input); err != nil {
+			return fmt.Errorf("%s: %w", parameter, err)
+		}
+	}
+	if !*r.prune {
+		return nil
+	}
+	for start := 0; start < len(plan.removed); start += ssmDeleteBatchSize {
+		end := start + ssmDeleteBatchSize
+		if end > len(plan.removed) {
+			end = len(plan.removed)
+		}
+		var parameters []string
+		for _, name := range plan.removed[start:end] {
+			parameter, _ := r.parameterName(name)
+			parameters = append(parameters, parameter)
+		}
+		output, err := client.DeleteParameters(ctx, &ssm.DeleteParametersInput{Names: parameters})
+		if err != nil {
+			return err
+		}
+		if len(output.InvalidParameters) > 0 {
+			return fmt.Errorf("could not delete %s", strings.Join(output.InvalidParameters, ", "))
+		}
+	}
+	return nil
+}
+
+type ssmPull struct {
+	ssmSync
+}
+
+// NewSsmPull configures the command to copy secrets from SSM Parameter Store.
+func NewSsmPull(c *kingpin.CmdClause) shared.Command {
+	return &ssmPull{ssmSync: newSsmSync(c)}
+}
+
+// Run runs the command.
+func (r *ssmPull) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename, store.LockTimeout(*r.lockTimeout))
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	cfg, err := myAWS.NewConfig(ctx)
+	if err != nil {
+		return err
+	}
+	parameters, err := r.parameters(ctx, ssm.NewFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+
+	var plan syncPlan
+	for name, value := range parameters {
+		entry, present := entries[name]
+		if name == store.KeyTemplateName {
+			return fmt.Errorf("refusing to overwrite the %s template from SSM", store.KeyTemplateName)
+		}
+		if !present {
+			plan.added = append(plan.added, name)
+			continue
+		}
+		current, err := r.encode(ctx, name, entry)
+		if err != nil {
+			return err
+		}
+		if current == value {
+			plan.unchanged++
+			continue
+		}
+		plan.changed = append(plan.changed, name)
+	}
+	names, err := selectNames(entries, nil)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, present := parameters[name]; present || !r.selected(name) {
+			continue
+		}
+		plan.removed = append(plan.removed, name)
+	}
+	plan.sort()
+	plan.print(func(name string) string { return name }, *r.prune)
+	if *r.dryRun || plan.empty() {
+		return nil
+	}
+
+	updated := make(map[string]store.ValueList)
+	var keys []store.Key
+	if !*r.encrypted {
+		if keys, err = database.GetKeyIds(); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w; create it with kms init or passphrase init first", err)
+		} else if err != nil {
+			return err
+		}
+		if err := checkPassphraseKeys(keys); err != nil {
+			return err
+		}
+	}
+	for _, name := range append(plan.added, plan.changed...) {
+		if *r.encrypted {
+			var values store.ValueList
+			if err := yaml.Unmarshal([]byte(parameters[name]), &values); err != nil {
+				return fmt.Errorf("%s: not a biscuit-encrypted value: %w", name, err)
+			}
+			updated[name] = values
+			continue
+		}
+		if updated[name], err = encryptAll(ctx, keys, name, []byte(parameters[name])); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	updatedBy := identity.Caller(ctx, keys)
+	return database.Update(func(current store.EntryMap) error {
+		for name := range updated {
+			if !reflect.DeepEqual(current[name], entries[name]) {
+				return fmt.Errorf("%s was modified by another process; no changes were saved", name)
+			}
+		}
+		for name, values := range updated {
+			entry := current[name]
+			entry.SupersedeBy(values, current.HistoryLimit(), updatedBy)
+			current[name] = entry
+		}
+		if *r.prune {
+			for _, name := range plan.removed {
+				delete(current, name)
+			}
+		}
+		return nil
+	})
+}
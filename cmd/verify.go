@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/aws/arn"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type verify struct {
+	names       *[]string
+	filename    *string
+	concurrency *int
+	failedOnly  *bool
+}
+
+// NewVerify configures the command to check that every value can be decrypted.
+func NewVerify(c *kingpin.CmdClause) shared.Command {
+	return &verify{
+		names: c.Arg("name", "Names of the secrets to verify. Shell-style glob patterns are "+
+			"supported. If omitted, all secrets are verified.").Strings(),
+		filename: shared.FilenameFlag(c),
+		concurrency: c.Flag("concurrency", "Maximum number of concurrent decryptions in each region.").
+			Default("4").
+			Int(),
+		failedOnly: c.Flag("failed-only", "Only show the values that could not be decrypted.").Bool(),
+	}
+}
+
+type verifyResult struct {
+	name   string
+	value  store.Value
+	region string
+	err    error
+}
+
+// Run runs the command.
+func (r *verify) Run(ctx context.Context) error {
+	if *r.concurrency < 1 {
+		return errors.New("--concurrency must be at least 1")
+	}
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.names)
+	if err != nil {
+		return err
+	}
+
+	// Each region gets its own pool of workers, so that a slow or unavailable region does not hold
+	// up the others.
+	byRegion := make(map[string][]verifyResult)
+	for _, name := range names {
+		for _, value := range entries[name].Values {
+			region := valueRegion(value)
+			byRegion[region] = append(byRegion[region], verifyResult{name: name, value: value, region: region})
+		}
+	}
+	var results []verifyResult
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, pending := range byRegion {
+		work := make(chan verifyResult, len(pending))
+		for _, result := range pending {
+			work <- result
+		}
+		close(work)
+		for i := 0; i < *r.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for result := range work {
+					_, result.err = decryptOneValue(ctx, result.value, result.name)
+					mu.Lock()
+					results = append(results, result)
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].name != results[j].name {
+			return results[i].name < results[j].name
+		}
+		return describeKey(results[i].value.Key) < describeKey(results[j].value.Key)
+	})
+	failures := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKEY MANAGER\tKEY ID\tREGION\tSTATUS")
+	for _, result := range results {
+		status := "ok"
+		if result.err != nil {
+			failures++
+			status = "FAILED: " + result.err.Error()
+		} else if *r.failedOnly {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.name, orDash(result.value.KeyManager),
+			orDash(result.value.KeyID), orDash(result.region), status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d %s could not be decrypted", failures, len(results),
+			stringsFunc.Pluralize("value", len(results)))
+	}
+	fmt.Printf("All %d %s decrypted successfully.\n", len(results),
+		stringsFunc.Pluralize("value", len(results)))
+	return nil
+}
+
+// valueRegion returns the AWS region of the KMS key protecting value, or an empty string if it is
+// not protected by KMS.
+func valueRegion(value store.Value) string {
+	if value.KeyManager != keymanager.KmsLabel {
+		return ""
+	}
+	parsed, err := arn.New(value.KeyID)
+	if err != nil {
+		return ""
+	}
+	return parsed.Region
+}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/atomicfile"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// watchInitialBackoff is how long watch waits before retrying after the first failure. The wait
+// doubles after each consecutive failure, up to --max-backoff.
+const watchInitialBackoff = time.Second
+
+type watch struct {
+	filename       *string
+	regionPriority *[]string
+	templates      *[]string
+	debounce       *time.Duration
+	maxBackoff     *time.Duration
+	pollInterval   *time.Duration
+	signal         *string
+	pid            *int
+	pidFile        *string
+	command        *string
+}
+
+// watchTarget is a template and the file that it is rendered to.
+type watchTarget struct {
+	template, output string
+	// secrets are the names of the secrets that the template referred to when it was last
+	// rendered, or nil if it has not been rendered successfully.
+	secrets map[string]struct{}
+}
+
+// NewWatch configures the command to keep rendered templates up to date as the store changes.
+func NewWatch(c *kingpin.CmdClause) shared.Command {
+	var signals []string
+	for name := range namedSignals {
+		signals = append(signals, name)
+	}
+	sort.Strings(signals)
+	return &watch{
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		templates: c.Flag("template", "Render TEMPLATE to OUTPUT, as the render command does, "+
+			"whenever the secrets it refers to change. May be repeated.").
+			PlaceHolder("TEMPLATE:OUTPUT").
+			Required().
+			Strings(),
+		debounce: c.Flag("debounce", "How long to wait for further changes to the store before "+
+			"rendering.").
+			Default("1s").
+			Duration(),
+		maxBackoff: c.Flag("max-backoff", "Longest time to wait before retrying after a failure. "+
+			"Retries start after 1s, and the wait doubles after each consecutive failure.").
+			Default("1m").
+			Duration(),
+		pollInterval: c.Flag("poll-interval", "How often to check stores that are not on the local "+
+			"filesystem for changes. Local stores are watched for changes instead.").
+			Default("1m").
+			Duration(),
+		signal: c.Flag("signal", "Signal to send to the process given by --pid or --pid-file after "+
+			"rewriting any files. Options: "+strings.Join(signals, ", ")).
+			PlaceHolder("SIGNAL").
+			Enum(signals...),
+		pid: c.Flag("pid", "Process to send --signal to.").PlaceHolder("PID").Int(),
+		pidFile: c.Flag("pid-file", "File containing the process ID to send --signal to. It is "+
+			"read each time a signal is sent.").
+			PlaceHolder("FILE").
+			String(),
+		command: c.Flag("command", "Shell command to run after rewriting any files.").
+			PlaceHolder("COMMAND").
+			String(),
+	}
+}
+
+// Run runs the command.
+func (r *watch) Run(ctx context.Context) error {
+	var targets []*watchTarget
+	for _, spec := range *r.templates {
+		separator := strings.LastIndex(spec, ":")
+		if separator <= 0 || separator == len(spec)-1 {
+			return fmt.Errorf("--template %s: expected TEMPLATE:OUTPUT", spec)
+		}
+		target := &watchTarget{template: spec[:separator], output: spec[separator+1:]}
+		if _, err := os.Stat(target.template); err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	}
+	if len(*r.signal) > 0 && *r.pid == 0 && len(*r.pidFile) == 0 {
+		return errors.New("--signal requires --pid or --pid-file")
+	}
+
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	cache, err := newSecretCache(database, *r.regionPriority, noExpiry)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	changes := make(chan struct{}, 1)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- store.Watch(ctx, database, *r.pollInterval, func() {
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	log.Printf("Watching %s", *r.filename)
+	var changed []string
+	notify := false
+	backoff := time.Duration(0)
+	next := time.After(0)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Shutting down")
+			return nil
+		case err := <-watchErr:
+			if err != nil {
+				return fmt.Errorf("no longer watching %s for changes: %w", *r.filename, err)
+			}
+			return nil
+		case <-changes:
+			next = time.After(*r.debounce)
+		case <-next:
+			// Secrets that changed, and whether a notification is owed, are remembered across
+			// failures so that the retry finishes the job.
+			reloaded, err := cache.reload()
+			if err == nil {
+				changed = append(changed, reloaded...)
+				var wrote bool
+				wrote, err = r.renderTargets(ctx, cache, targets, changed)
+				notify = notify || wrote
+			}
+			if err == nil {
+				changed = nil
+				if notify {
+					err = r.notify()
+				}
+			}
+			if err == nil {
+				notify = false
+				backoff = 0
+				next = nil
+				continue
+			}
+			if backoff = backoff * 2; backoff < watchInitialBackoff {
+				backoff = watchInitialBackoff
+			} else if backoff > *r.maxBackoff {
+				backoff = *r.maxBackoff
+			}
+			log.Printf("%s; retrying in %s", err, backoff)
+			next = time.After(backoff)
+		}
+	}
+}
+
+// renderTargets renders the targets that have not been rendered yet or that refer to any of the
+// changed secrets, and rewrites the outputs whose contents changed. It returns true if any output
+// was rewritten.
+func (r *watch) renderTargets(ctx context.Context, cache *secretCache, targets []*watchTarget,
+	changed []string) (bool, error) {
+	wrote := false
+	for _, target := range targets {
+		if target.secrets != nil && !refersToAny(target.secrets, changed) {
+			continue
+		}
+		secrets := make(map[string]struct{})
+		rendered, err := renderTemplate(target.template, func(name string) (string, error) {
+			secrets[name] = struct{}{}
+			return cache.get(ctx, name)
+		})
+		if err != nil {
+			return wrote, err
+		}
+		target.secrets = secrets
+		if current, err := os.ReadFile(target.output); err == nil && bytes.Equal(current, rendered) {
+			continue
+		}
+		if err := atomicfile.WriteFile(target.output, rendered, 0600); err != nil {
+			return wrote, err
+		}
+		log.Printf("Wrote %s", target.output)
+		wrote = true
+	}
+	return wrote, nil
+}
+
+func refersToAny(secrets map[string]struct{}, names []string) bool {
+	for _, name := range names {
+		if _, present := secrets[name]; present {
+			return true
+		}
+	}
+	return false
+}
+
+// notify sends --signal and runs --command, if they were given.
+func (r *watch) notify() error {
+	if len(*r.signal) > 0 {
+		pid := *r.pid
+		if len(*r.pidFile) > 0 {
+			contents, err := os.ReadFile(*r.pidFile)
+			if err != nil {
+				return err
+			}
+			if pid, err = strconv.Atoi(strings.TrimSpace(string(contents))); err != nil {
+				return fmt.Errorf("%s: %w", *r.pidFile, err)
+			}
+		}
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return err
+		}
+		if err := process.Signal(namedSignals[*r.signal]); err != nil {
+			return fmt.Errorf("could not send %s to process %d: %w", *r.signal, pid, err)
+		}
+		log.Printf("Sent %s to process %d", *r.signal, pid)
+	}
+	if len(*r.command) > 0 {
+		args := shellCommand(*r.command)
+		child := exec.Command(args[0], args[1:]...)
+		child.Stdout = os.Stdout
+		child.Stderr = os.Stderr
+		if err := child.Run(); err != nil {
+			return fmt.Errorf("%s: %w", *r.command, err)
+		}
+		log.Printf("Ran %s", *r.command)
+	}
+	return nil
+}
@@ -3,22 +3,27 @@ module github.com/dcoker/biscuit
 go 1.16
 
 require (
+	filippo.io/age v1.0.0
 	github.com/alecthomas/assert v0.0.0-20170929043011-405dbfeb8e38 // indirect
 	github.com/alecthomas/colour v0.1.0 // indirect
 	github.com/alecthomas/repr v0.0.0-20210801044451-80ca428c5142 // indirect
 	github.com/alecthomas/template v0.0.0-20160405071501-a0175ee3bccc // indirect
 	github.com/alecthomas/units v0.0.0-20151022065526-2efee857e7cf // indirect
-	github.com/aws/aws-sdk-go-v2 v1.9.0
+	github.com/aws/aws-sdk-go-v2 v1.9.1
 	github.com/aws/aws-sdk-go-v2/config v1.8.1
 	github.com/aws/aws-sdk-go-v2/service/cloudformation v1.10.0
 	github.com/aws/aws-sdk-go-v2/service/kms v1.6.0
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.15.0
+	github.com/aws/aws-sdk-go-v2/service/ssm v1.11.0
 	github.com/aws/aws-sdk-go-v2/service/sts v1.7.0
 	github.com/aws/smithy-go v1.8.0
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/mattn/go-isatty v0.0.0-20151211000621-56b76bdf51f7
 	github.com/sergi/go-diff v1.2.0 // indirect
 	github.com/stretchr/testify v1.4.0
-	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
-	golang.org/x/sys v0.0.0-20210816074244-15123e1e1f71 // indirect
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+	golang.org/x/sys v0.0.0-20210903071746-97244b99971b
+	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b
 	gopkg.in/alecthomas/kingpin.v2 v2.1.11
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v2 v2.2.8
@@ -0,0 +1,44 @@
+// Package atomicfile replaces files so that readers, and the file system after a crash, see
+// either the old contents or the new ones.
+package atomicfile
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces filename with contents and sets its permissions to perm. The contents are
+// written and synced to a hidden temporary file in the same directory, which is then renamed over
+// filename, and the directory is synced so that the rename survives a crash. The temporary file
+// is only readable by the current user until it has been written.
+func WriteFile(filename string, contents []byte, perm fs.FileMode) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	// CreateTemp creates the file with permissions 0600.
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+	return SyncDir(dir)
+}
@@ -0,0 +1,34 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestWriteFile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "secrets.yml")
+
+	assert.NoError(t, WriteFile(filename, []byte("one"), 0644))
+	assert.NoError(t, WriteFile(filename, []byte("two"), 0600))
+	contents, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "two", string(contents))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// No temporary files are left behind, even when the write fails.
+	assert.Error(t, WriteFile(filepath.Join(dir, "missing", "secrets.yml"), []byte("three"), 0644))
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package atomicfile
+
+import "os"
+
+// SyncDir flushes the entries of a directory, such as a renamed or removed file, to disk.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package atomicfile
+
+// SyncDir is a no-op; Windows does not support flushing directory handles.
+func SyncDir(string) error {
+	return nil
+}
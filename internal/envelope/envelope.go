@@ -0,0 +1,96 @@
+// Package envelope encrypts and decrypts the values of secrets with data keys from a key manager.
+package envelope
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+)
+
+// KeyManagers returns the KeyManager for a label, such as keymanager.New.
+type KeyManagers func(label string) (keymanager.KeyManager, error)
+
+// Encrypt encrypts plaintext for the secret name under key.
+func Encrypt(ctx context.Context, keyManagers KeyManagers, key store.Key, name string,
+	plaintext []byte) (store.Value, error) {
+	var value store.Value
+	algo, err := algorithms.Get(key.Algorithm)
+	if err != nil {
+		return value, err
+	}
+	value.Algorithm = key.Algorithm
+
+	var envelopeKey keymanager.EnvelopeKey
+	if algo.NeedsKey() {
+		keyManager, err := keyManagers(key.KeyManager)
+		if err != nil {
+			return value, err
+		}
+		value.KeyManager = keyManager.Label()
+		envelopeKey, err = keyManager.GenerateEnvelopeKey(ctx, key.KeyID, name)
+		if err != nil {
+			return value, err
+		}
+		value.KeyID = envelopeKey.ResolvedID
+		value.KeyCiphertext = base64.StdEncoding.EncodeToString(envelopeKey.Ciphertext)
+		rotatedAt := time.Now().UTC().Truncate(time.Second)
+		value.RotatedAt = &rotatedAt
+	}
+
+	var ciphertext []byte
+	if authenticated, ok := algo.(algorithms.AuthenticatedAlgorithm); ok && algo.NeedsKey() {
+		value.FormatVersion = store.FormatAssociatedData
+		ciphertext, err = authenticated.EncryptWithAAD(envelopeKey.Plaintext, plaintext, value.AssociatedData(name))
+	} else {
+		ciphertext, err = algo.Encrypt(envelopeKey.Plaintext, plaintext)
+	}
+	if err != nil {
+		return value, err
+	}
+	value.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	return value, nil
+}
+
+// Decrypt returns the plaintext of value, which holds the secret name.
+func Decrypt(ctx context.Context, keyManagers KeyManagers, value store.Value, name string) ([]byte, error) {
+	algo, err := algorithms.Get(value.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var keyPlaintext []byte
+	if algo.NeedsKey() {
+		keyManager, err := keyManagers(value.KeyManager)
+		if err != nil {
+			return nil, err
+		}
+		keyCiphertext, err := value.GetKeyCiphertext()
+		if err != nil {
+			return nil, err
+		}
+		keyPlaintext, err = keyManager.Decrypt(ctx, value.Key.KeyID, keyCiphertext, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	decoded, err := value.GetCiphertext()
+	if err != nil {
+		return nil, err
+	}
+	switch value.FormatVersion {
+	case store.FormatLegacy:
+		return algo.Decrypt(keyPlaintext, decoded)
+	case store.FormatAssociatedData:
+		authenticated, ok := algo.(algorithms.AuthenticatedAlgorithm)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s does not support associated data", value.Algorithm)
+		}
+		return authenticated.DecryptWithAAD(keyPlaintext, decoded, value.AssociatedData(name))
+	default:
+		return nil, fmt.Errorf("unsupported format version %d", value.FormatVersion)
+	}
+}
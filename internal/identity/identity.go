@@ -0,0 +1,64 @@
+// Package identity describes who is writing to a store.
+package identity
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/dcoker/biscuit/algorithms"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+)
+
+// callerTimeout bounds how long a write waits to learn who is making it.
+const callerTimeout = 5 * time.Second
+
+// awsCaller returns the ARN of the AWS principal whose credentials are in use.
+var awsCaller = func(ctx context.Context) (string, error) {
+	cfg, err := myAWS.NewConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.Arn), nil
+}
+
+// Caller returns a description of who is writing a secret: the AWS principal ARN if any of
+// keys encrypt with KMS and credentials are available, otherwise the local user name. AWS is
+// only asked when a KMS key is in use, so writes that only use other key managers do not wait
+// on the network.
+func Caller(ctx context.Context, keys []store.Key) string {
+	if usesKms(keys) {
+		ctx, cancel := context.WithTimeout(ctx, callerTimeout)
+		defer cancel()
+		if arn, err := awsCaller(ctx); err == nil && arn != "" {
+			return arn
+		}
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// usesKms returns true if any of keys encrypts data keys with KMS. Keys whose algorithm does not
+// use a data key, such as none, do not.
+func usesKms(keys []store.Key) bool {
+	for _, key := range keys {
+		if key.KeyManager != keymanager.KmsLabel {
+			continue
+		}
+		if algo, err := algorithms.Get(key.Algorithm); err == nil && algo.NeedsKey() {
+			return true
+		}
+	}
+	return false
+}
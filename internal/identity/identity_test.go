@@ -0,0 +1,43 @@
+package identity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/algorithms/plain"
+	"github.com/dcoker/biscuit/algorithms/secretbox"
+	"github.com/dcoker/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaller(t *testing.T) {
+	_ = algorithms.Register(secretbox.Name, secretbox.New())
+	_ = algorithms.Register(plain.Name, plain.New())
+	const arn = "arn:aws:iam::123456789012:user/gordon"
+	asked := 0
+	defer func(original func(context.Context) (string, error)) { awsCaller = original }(awsCaller)
+	awsCaller = func(context.Context) (string, error) {
+		asked++
+		return arn, nil
+	}
+
+	ctx := context.Background()
+	for _, keys := range [][]store.Key{
+		nil,
+		{{KeyManager: "passphrase", KeyID: "scrypt", Algorithm: secretbox.Name}},
+		{{KeyManager: "age", KeyID: "age1", Algorithm: secretbox.Name}},
+		{{KeyManager: "kms", KeyID: "alias/biscuit", Algorithm: plain.Name}},
+		{{Algorithm: plain.Name}},
+	} {
+		assert.NotEqual(t, arn, Caller(ctx, keys))
+	}
+	assert.Equal(t, 0, asked)
+
+	keys := []store.Key{
+		{KeyManager: "passphrase", KeyID: "scrypt", Algorithm: secretbox.Name},
+		{KeyManager: "kms", KeyID: "alias/biscuit", Algorithm: secretbox.Name},
+	}
+	assert.Equal(t, arn, Caller(ctx, keys))
+	assert.Equal(t, 1, asked)
+}
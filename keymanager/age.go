@@ -0,0 +1,151 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"filippo.io/age"
+)
+
+const (
+	// AgeLabel is the label for the age key manager.
+	AgeLabel = "age"
+)
+
+var (
+	errNoAgeIdentity   = errors.New("age: set BISCUIT_AGE_IDENTITY to the path of an age identity file")
+	errAgeNameMismatch = errors.New("age: key was encrypted for a different secret name")
+
+	ageIdentities struct {
+		once       sync.Once
+		identities []age.Identity
+		err        error
+	}
+)
+
+func init() {
+	registry[AgeLabel] = newAgeKeyManager
+}
+
+// ageKeys is a KeyManager that wraps data keys for an age X25519 recipient. Key IDs are age
+// recipients (public keys, ex: age1...). Decryption uses the identities (private keys) in the
+// files listed in BISCUIT_AGE_IDENTITY.
+//
+// age has no equivalent of a KMS encryption context, so the secret name is encrypted along with
+// the data key and checked on decryption.
+type ageKeys struct {
+	identities func() ([]age.Identity, error)
+}
+
+func newAgeKeyManager() KeyManager {
+	return &ageKeys{identities: loadAgeIdentities}
+}
+
+// GenerateEnvelopeKey generates a random data key and encrypts it to the recipient keyID.
+func (a *ageKeys) GenerateEnvelopeKey(_ context.Context, keyID, secretID string) (EnvelopeKey, error) {
+	recipient, err := age.ParseX25519Recipient(keyID)
+	if err != nil {
+		return EnvelopeKey{}, fmt.Errorf("age: %w", err)
+	}
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return EnvelopeKey{}, err
+	}
+
+	var ciphertext bytes.Buffer
+	w, err := age.Encrypt(&ciphertext, recipient)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	if _, err := w.Write(bindSecretID(secretID, plaintext)); err != nil {
+		return EnvelopeKey{}, err
+	}
+	if err := w.Close(); err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: recipient.String(),
+		Plaintext:  plaintext,
+		Ciphertext: ciphertext.Bytes(),
+	}, nil
+}
+
+// Decrypt decrypts the data key with one of the configured identities.
+func (a *ageKeys) Decrypt(_ context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	identities, err := a.identities()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(keyCiphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("age: %s: %w", keyID, err)
+	}
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("age: %s: %w", keyID, err)
+	}
+	return unbindSecretID(secretID, payload)
+}
+
+// Label returns AgeLabel.
+func (a *ageKeys) Label() string {
+	return AgeLabel
+}
+
+// bindSecretID prefixes key with the length-prefixed secretID.
+func bindSecretID(secretID string, key []byte) []byte {
+	var buf bytes.Buffer
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(secretID)))
+	buf.Write(length[:])
+	buf.WriteString(secretID)
+	buf.Write(key)
+	return buf.Bytes()
+}
+
+// unbindSecretID returns the key from a payload created by bindSecretID, verifying that it was
+// bound to secretID.
+func unbindSecretID(secretID string, payload []byte) ([]byte, error) {
+	if len(payload) < 4 {
+		return nil, errAgeNameMismatch
+	}
+	length := binary.BigEndian.Uint32(payload[:4])
+	if uint64(len(payload)-4) < uint64(length) || string(payload[4:4+length]) != secretID {
+		return nil, errAgeNameMismatch
+	}
+	return payload[4+length:], nil
+}
+
+// loadAgeIdentities reads the identity files listed in BISCUIT_AGE_IDENTITY once per process.
+func loadAgeIdentities() ([]age.Identity, error) {
+	ageIdentities.once.Do(func() {
+		paths := filepath.SplitList(os.Getenv("BISCUIT_AGE_IDENTITY"))
+		if len(paths) == 0 {
+			ageIdentities.err = errNoAgeIdentity
+			return
+		}
+		for _, path := range paths {
+			f, err := os.Open(path)
+			if err != nil {
+				ageIdentities.err = fmt.Errorf("age: could not read identity file: %w", err)
+				return
+			}
+			identities, err := age.ParseIdentities(f)
+			f.Close()
+			if err != nil {
+				ageIdentities.err = fmt.Errorf("age: %s: %w", path, err)
+				return
+			}
+			ageIdentities.identities = append(ageIdentities.identities, identities...)
+		}
+	})
+	return ageIdentities.identities, ageIdentities.err
+}
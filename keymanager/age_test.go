@@ -0,0 +1,41 @@
+package keymanager
+
+import (
+	"context"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAge_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	other, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	manager := &ageKeys{identities: func() ([]age.Identity, error) {
+		return []age.Identity{identity}, nil
+	}}
+	envelope, err := manager.GenerateEnvelopeKey(ctx, identity.Recipient().String(), "launch_codes")
+	assert.NoError(t, err)
+	assert.Equal(t, identity.Recipient().String(), envelope.ResolvedID)
+	assert.Len(t, envelope.Plaintext, 32)
+
+	plaintext, err := manager.Decrypt(ctx, envelope.ResolvedID, envelope.Ciphertext, "launch_codes")
+	assert.NoError(t, err)
+	assert.Equal(t, envelope.Plaintext, plaintext)
+
+	_, err = manager.Decrypt(ctx, envelope.ResolvedID, envelope.Ciphertext, "renamed")
+	assert.Equal(t, errAgeNameMismatch, err)
+
+	wrongIdentity := &ageKeys{identities: func() ([]age.Identity, error) {
+		return []age.Identity{other}, nil
+	}}
+	_, err = wrongIdentity.Decrypt(ctx, envelope.ResolvedID, envelope.Ciphertext, "launch_codes")
+	assert.Error(t, err)
+
+	_, err = manager.GenerateEnvelopeKey(ctx, "not a recipient", "launch_codes")
+	assert.Error(t, err)
+}
@@ -10,12 +10,13 @@ var (
 	registry = make(map[string]func() KeyManager)
 )
 
-type errUnsupportedKeyManager struct {
-	label string
+// UnsupportedKeyManagerError is returned by New if no key manager is registered with the label.
+type UnsupportedKeyManagerError struct {
+	Label string
 }
 
-func (e *errUnsupportedKeyManager) Error() string {
-	return fmt.Sprintf("unsupported key manager '%s'", e.label)
+func (e *UnsupportedKeyManagerError) Error() string {
+	return fmt.Sprintf("unsupported key manager '%s'", e.Label)
 }
 
 // New returns a KeyManager of the requested type.
@@ -23,7 +24,7 @@ func New(label string) (KeyManager, error) {
 	if constructor, present := registry[label]; present {
 		return constructor(), nil
 	}
-	return nil, &errUnsupportedKeyManager{label}
+	return nil, &UnsupportedKeyManagerError{Label: label}
 }
 
 // GetDefaultKeyManager returns the default key manager label.
@@ -0,0 +1,372 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	// PassphraseLabel is the label for the passphrase key manager.
+	PassphraseLabel = "passphrase"
+
+	// ScryptKdf derives passphrase keys with scrypt.
+	ScryptKdf = "scrypt"
+	// Argon2idKdf derives passphrase keys with Argon2id.
+	Argon2idKdf = "argon2id"
+
+	passphraseSaltBytes  = 16
+	passphraseCheckBytes = 8
+)
+
+var (
+	defaultKdfParams = map[string]string{
+		ScryptKdf:   "N=32768,r=8,p=1",
+		Argon2idKdf: "t=3,m=65536,p=4",
+	}
+
+	errNoPassphrase = errors.New("no passphrase available: set BISCUIT_PASSPHRASE or " +
+		"BISCUIT_PASSPHRASE_FILE, or run biscuit from a terminal")
+	errPassphraseMismatch  = errors.New("passphrases do not match")
+	errIncorrectPassphrase = errors.New("incorrect passphrase")
+	errKeyCiphertextShort  = errors.New("passphrase: key ciphertext too short")
+	errUnableToUnwrap      = errors.New("passphrase: unable to decrypt key; the passphrase or secret name " +
+		"may be incorrect")
+
+	defaultPassphraseCache = &passphraseCache{read: readPassphrase}
+)
+
+func init() {
+	registry[PassphraseLabel] = newPassphraseKeyManager
+}
+
+// passphraseKeys is a KeyManager that wraps data keys with a key derived from a passphrase.
+//
+// Key IDs have the form KDF[:PARAMS[:SALT[:CHECK]]], where KDF is scrypt or argon2id, PARAMS
+// tunes the cost of the KDF (ex: N=32768,r=8,p=1 or t=3,m=65536,p=4), SALT is the base64 salt,
+// and CHECK is a short MAC used to detect an incorrect passphrase before encrypting. When a key
+// ID without a salt is used for encryption, a new salt is generated and the resolved key ID
+// records it.
+type passphraseKeys struct {
+	cache *passphraseCache
+}
+
+func newPassphraseKeyManager() KeyManager {
+	return &passphraseKeys{cache: defaultPassphraseCache}
+}
+
+// GenerateEnvelopeKey generates a random data key and wraps it with the key derived from the
+// passphrase.
+func (p *passphraseKeys) GenerateEnvelopeKey(_ context.Context, keyID, secretID string) (EnvelopeKey, error) {
+	spec, err := parsePassphraseKeyID(keyID)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	newSalt := spec.salt == nil
+	if newSalt {
+		spec.salt = make([]byte, passphraseSaltBytes)
+		if _, err := rand.Read(spec.salt); err != nil {
+			return EnvelopeKey{}, err
+		}
+	}
+	kek, err := p.cache.kek(spec, newSalt)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	if spec.check != nil && !hmac.Equal(spec.check, passphraseCheck(kek)) {
+		return EnvelopeKey{}, errIncorrectPassphrase
+	}
+	spec.check = passphraseCheck(kek)
+
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return EnvelopeKey{}, err
+	}
+	ciphertext, err := wrapKey(kek, plaintext, []byte(secretID))
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: spec.String(),
+		Plaintext:  plaintext,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Decrypt unwraps the data key with the key derived from the passphrase.
+func (p *passphraseKeys) Decrypt(_ context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	spec, err := parsePassphraseKeyID(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if spec.salt == nil {
+		return nil, fmt.Errorf("passphrase: key ID %s has no salt", keyID)
+	}
+	kek, err := p.cache.kek(spec, false)
+	if err != nil {
+		return nil, err
+	}
+	if spec.check != nil && !hmac.Equal(spec.check, passphraseCheck(kek)) {
+		return nil, errIncorrectPassphrase
+	}
+	return unwrapKey(kek, keyCiphertext, []byte(secretID))
+}
+
+// Label returns PassphraseLabel.
+func (p *passphraseKeys) Label() string {
+	return PassphraseLabel
+}
+
+type passphraseKeyID struct {
+	kdf,
+	params string
+	salt,
+	check []byte
+}
+
+func parsePassphraseKeyID(keyID string) (passphraseKeyID, error) {
+	if keyID == "" {
+		keyID = ScryptKdf
+	}
+	parts := strings.Split(keyID, ":")
+	if len(parts) > 4 {
+		return passphraseKeyID{}, fmt.Errorf("passphrase: invalid key ID %s", keyID)
+	}
+	spec := passphraseKeyID{kdf: parts[0]}
+	if _, known := defaultKdfParams[spec.kdf]; !known {
+		return passphraseKeyID{}, fmt.Errorf("passphrase: unsupported KDF '%s'", spec.kdf)
+	}
+	spec.params = defaultKdfParams[spec.kdf]
+	if len(parts) > 1 && parts[1] != "" {
+		spec.params = parts[1]
+	}
+	if _, err := spec.derive(nil); err != nil {
+		return passphraseKeyID{}, err
+	}
+	var err error
+	if len(parts) > 2 {
+		if spec.salt, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+			return passphraseKeyID{}, fmt.Errorf("passphrase: invalid salt: %w", err)
+		}
+	}
+	if len(parts) > 3 {
+		if spec.check, err = base64.RawURLEncoding.DecodeString(parts[3]); err != nil {
+			return passphraseKeyID{}, fmt.Errorf("passphrase: invalid check value: %w", err)
+		}
+	}
+	return spec, nil
+}
+
+// MatchPassphraseKeyID returns keyID if it records a salt and check value. Otherwise it returns
+// the first of candidates that does, and that uses the KDF and parameters of keyID and, if keyID
+// has one, its salt. It returns an empty string if there is no such key ID.
+func MatchPassphraseKeyID(keyID string, candidates []string) string {
+	spec, err := parsePassphraseKeyID(keyID)
+	if err != nil {
+		return ""
+	}
+	if spec.salt != nil && spec.check != nil {
+		return keyID
+	}
+	for _, candidate := range candidates {
+		other, err := parsePassphraseKeyID(candidate)
+		if err != nil || other.salt == nil || other.check == nil {
+			continue
+		}
+		if other.kdf == spec.kdf && other.params == spec.params &&
+			(spec.salt == nil || bytes.Equal(other.salt, spec.salt)) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func (s passphraseKeyID) String() string {
+	fields := []string{s.kdf, s.params, base64.RawURLEncoding.EncodeToString(s.salt)}
+	if s.check != nil {
+		fields = append(fields, base64.RawURLEncoding.EncodeToString(s.check))
+	}
+	return strings.Join(fields, ":")
+}
+
+// derive computes the key-encryption key from passphrase. A nil passphrase only validates the
+// parameters.
+func (s passphraseKeyID) derive(passphrase []byte) ([]byte, error) {
+	params, err := parseKdfParams(s.params)
+	if err != nil {
+		return nil, err
+	}
+	switch s.kdf {
+	case ScryptKdf:
+		n, r, p := params["N"], params["r"], params["p"]
+		if len(params) != 3 || n < 2 || n&(n-1) != 0 || r < 1 || p < 1 {
+			return nil, fmt.Errorf("passphrase: scrypt parameters must be N=<power of 2>,r=<n>,p=<n>: %s",
+				s.params)
+		}
+		if passphrase == nil {
+			return nil, nil
+		}
+		return scrypt.Key(passphrase, s.salt, n, r, p, 32)
+	case Argon2idKdf:
+		t, m, p := params["t"], params["m"], params["p"]
+		if len(params) != 3 || t < 1 || m < 8 || p < 1 || p > 255 {
+			return nil, fmt.Errorf("passphrase: argon2id parameters must be t=<n>,m=<KiB>,p=<n>: %s",
+				s.params)
+		}
+		if passphrase == nil {
+			return nil, nil
+		}
+		return argon2.IDKey(passphrase, s.salt, uint32(t), uint32(m), uint8(p), 32), nil
+	}
+	return nil, fmt.Errorf("passphrase: unsupported KDF '%s'", s.kdf)
+}
+
+func parseKdfParams(params string) (map[string]int, error) {
+	parsed := make(map[string]int)
+	for _, param := range strings.Split(params, ",") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("passphrase: invalid KDF parameter '%s'", param)
+		}
+		value, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("passphrase: invalid KDF parameter '%s': %w", param, err)
+		}
+		parsed[kv[0]] = value
+	}
+	return parsed, nil
+}
+
+// passphraseCheck returns a short value that identifies kek without revealing it.
+func passphraseCheck(kek []byte) []byte {
+	mac := hmac.New(sha256.New, kek)
+	mac.Write([]byte("biscuit passphrase check"))
+	return mac.Sum(nil)[:passphraseCheckBytes]
+}
+
+func wrapKey(kek, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newKeyWrapper(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func unwrapKey(kek, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := newKeyWrapper(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errKeyCiphertextShort
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, errUnableToUnwrap
+	}
+	return plaintext, nil
+}
+
+func newKeyWrapper(kek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// passphraseCache reads the passphrase at most once per process and remembers the keys derived
+// from it, since KDFs are deliberately slow and a command may use the same key many times.
+type passphraseCache struct {
+	read func(confirm bool) ([]byte, error)
+
+	mu         sync.Mutex
+	passphrase []byte
+	keks       map[string][]byte
+}
+
+func (c *passphraseCache) kek(spec passphraseKeyID, confirm bool) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.passphrase == nil {
+		passphrase, err := c.read(confirm)
+		if err != nil {
+			return nil, err
+		}
+		c.passphrase = passphrase
+	}
+	id := spec.kdf + ":" + spec.params + ":" + string(spec.salt)
+	if kek, present := c.keks[id]; present {
+		return kek, nil
+	}
+	kek, err := spec.derive(c.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if c.keks == nil {
+		c.keks = make(map[string][]byte)
+	}
+	c.keks[id] = kek
+	return kek, nil
+}
+
+// readPassphrase reads the passphrase from BISCUIT_PASSPHRASE, the file named by
+// BISCUIT_PASSPHRASE_FILE, or the terminal, in that order. When confirm is set and the
+// passphrase is read from the terminal, it must be entered twice.
+func readPassphrase(confirm bool) ([]byte, error) {
+	if passphrase := os.Getenv("BISCUIT_PASSPHRASE"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if filename := os.Getenv("BISCUIT_PASSPHRASE_FILE"); filename != "" {
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("could not read passphrase file %s: %w", filename, err)
+		}
+		return bytes.TrimRight(contents, "\r\n"), nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errNoPassphrase
+	}
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errNoPassphrase
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return nil, errPassphraseMismatch
+		}
+	}
+	return passphrase, nil
+}
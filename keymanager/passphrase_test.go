@@ -0,0 +1,85 @@
+package keymanager
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fixedPassphrase(passphrase string) *passphraseKeys {
+	return &passphraseKeys{cache: &passphraseCache{read: func(bool) ([]byte, error) {
+		return []byte(passphrase), nil
+	}}}
+}
+
+func TestPassphrase_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, keyID := range []string{"scrypt:N=1024,r=8,p=1", "argon2id:t=1,m=64,p=1"} {
+		manager := fixedPassphrase("correct horse")
+		envelope, err := manager.GenerateEnvelopeKey(ctx, keyID, "launch_codes")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(envelope.ResolvedID, keyID+":"))
+		assert.Len(t, envelope.Plaintext, 32)
+
+		plaintext, err := fixedPassphrase("correct horse").Decrypt(ctx, envelope.ResolvedID,
+			envelope.Ciphertext, "launch_codes")
+		assert.NoError(t, err)
+		assert.Equal(t, envelope.Plaintext, plaintext)
+
+		// The resolved key ID can be reused for encryption, keeping the same salt.
+		again, err := manager.GenerateEnvelopeKey(ctx, envelope.ResolvedID, "other")
+		assert.NoError(t, err)
+		assert.Equal(t, envelope.ResolvedID, again.ResolvedID)
+
+		_, err = fixedPassphrase("wrong").Decrypt(ctx, envelope.ResolvedID, envelope.Ciphertext,
+			"launch_codes")
+		assert.Equal(t, errIncorrectPassphrase, err)
+		_, err = fixedPassphrase("wrong").GenerateEnvelopeKey(ctx, envelope.ResolvedID, "launch_codes")
+		assert.Equal(t, errIncorrectPassphrase, err)
+
+		_, err = manager.Decrypt(ctx, envelope.ResolvedID, envelope.Ciphertext, "renamed")
+		assert.Error(t, err)
+	}
+}
+
+func TestPassphrase_MatchKeyID(t *testing.T) {
+	const scrypt = "scrypt:N=32768,r=8,p=1:wIFX-quldO2UybsZWDsmmA:SmLC8h3rwS8"
+	const argon2id = "argon2id:t=3,m=65536,p=4:wIFX-quldO2UybsZWDsmmA:SmLC8h3rwS8"
+	candidates := []string{"scrypt", "scrypt:N=1024,r=8,p=1:wIFX-quldO2UybsZWDsmmA", argon2id, scrypt}
+
+	assert.Equal(t, scrypt, MatchPassphraseKeyID("scrypt", candidates))
+	assert.Equal(t, scrypt, MatchPassphraseKeyID("", candidates))
+	assert.Equal(t, scrypt, MatchPassphraseKeyID("scrypt:N=32768,r=8,p=1:wIFX-quldO2UybsZWDsmmA",
+		candidates))
+	assert.Equal(t, argon2id, MatchPassphraseKeyID("argon2id", candidates))
+	assert.Equal(t, argon2id, MatchPassphraseKeyID(argon2id, nil))
+	assert.Equal(t, "", MatchPassphraseKeyID("scrypt:N=1024,r=8,p=1", candidates))
+	assert.Equal(t, "", MatchPassphraseKeyID("scrypt:N=32768,r=8,p=1:AAAA", candidates))
+	assert.Equal(t, "", MatchPassphraseKeyID("pbkdf2", candidates))
+}
+
+func TestPassphrase_ParseKeyID(t *testing.T) {
+	spec, err := parsePassphraseKeyID("")
+	assert.NoError(t, err)
+	assert.Equal(t, ScryptKdf, spec.kdf)
+	assert.Equal(t, defaultKdfParams[ScryptKdf], spec.params)
+	assert.Nil(t, spec.salt)
+
+	spec, err = parsePassphraseKeyID("argon2id")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultKdfParams[Argon2idKdf], spec.params)
+
+	for _, invalid := range []string{
+		"pbkdf2",
+		"scrypt:N=1000,r=8,p=1",
+		"scrypt:N=1024",
+		"argon2id:t=1,m=64,p=0",
+		"scrypt:N=1024,r=8,p=1:not base64!",
+		"scrypt:a:b:c:d",
+	} {
+		_, err := parsePassphraseKeyID(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -11,10 +11,7 @@ import (
 	"os"
 
 	"github.com/aws/smithy-go"
-	"github.com/dcoker/biscuit/algorithms"
-	"github.com/dcoker/biscuit/algorithms/aesgcm256"
-	"github.com/dcoker/biscuit/algorithms/plain"
-	"github.com/dcoker/biscuit/algorithms/secretbox"
+	"github.com/dcoker/biscuit/algorithms/builtin"
 	"github.com/dcoker/biscuit/cmd"
 	"github.com/dcoker/biscuit/cmd/awskms"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -27,22 +24,9 @@ var (
 //go:embed data/*
 var fileSystem embed.FS
 
-func registerAlgorithms() error {
-	if err := algorithms.Register(secretbox.Name, secretbox.New()); err != nil {
-		return err
-	}
-	if err := algorithms.Register(plain.Name, plain.New()); err != nil {
-		return err
-	}
-	if err := algorithms.Register(aesgcm256.Name, aesgcm256.New()); err != nil {
-		return err
-	}
-	return nil
-}
-
 func main() {
 	os.Setenv("COLUMNS", "80") // hack to make --help output readable
-	if err := registerAlgorithms(); err != nil {
+	if err := builtin.Register(); err != nil {
 		log.Fatal(err)
 	}
 	app := kingpin.New("biscuit", mustAsset("data/usage.txt"))
@@ -51,7 +35,36 @@ func main() {
 	getFlags := app.Command("get", "Read a secret.")
 	putFlags := app.Command("put", "Write a secret.")
 	listFlags := app.Command("list", "List secrets.")
-	exportFlags := app.Command("export", "Print all secrets to stdout in plaintext YAML.")
+	deleteFlags := app.Command("delete", "Delete secrets.")
+	editFlags := app.Command("edit", "Edit a secret with your editor ($VISUAL or $EDITOR).")
+	rekeyFlags := app.Command("rekey", "Re-encrypt secrets under the keys in the current template.")
+	rotateFlags := app.Command("rotate", "Re-encrypt secrets with new data keys under the same keys.")
+	historyFlags := app.Command("history", "List the versions of a secret.")
+	rollbackFlags := app.Command("rollback", "Restore a previous version of a secret.")
+	describeFlags := app.Command("describe", "Show the keys, regions, and algorithms that secrets "+
+		"are encrypted under, without decrypting them.")
+	verifyFlags := app.Command("verify", "Check that every value of every secret can be decrypted.")
+	migrateFlags := app.Command("migrate", "Copy all secrets to another store.")
+	syncFlags := app.Command("sync", "Synchronize secrets with other secret stores.")
+	syncSsmFlags := syncFlags.Command("ssm", "Synchronize secrets with AWS SSM Parameter Store.")
+	syncSsmPushFlags := syncSsmFlags.Command("push", "Write secrets to SecureString parameters, "+
+		"showing the changes before making them.")
+	syncSsmPullFlags := syncSsmFlags.Command("pull", "Read secrets from SecureString parameters "+
+		"into the store, showing the changes before making them.")
+	exportFlags := app.Command("export", "Print secrets to stdout in plaintext, as YAML, JSON, a .env file, "+
+		"shell commands, a Kubernetes Secret, or Terraform variables.")
+	importFlags := app.Command("import", "Encrypt secrets read from a .env file, JSON, YAML, or a "+
+		"Kubernetes Secret.")
+	renderFlags := app.Command("render", "Render a template containing secrets, such as a "+
+		"configuration file.")
+	agentFlags := app.Command("agent", "Serve decrypted secrets to local processes over a Unix "+
+		"socket, caching them in memory.")
+	serveFlags := app.Command("serve", "Serve decrypted secrets over HTTP, for use as a sidecar.")
+	watchFlags := app.Command("watch", "Render templates containing secrets, and render them again "+
+		"whenever the secrets change.")
+	execFlags := app.Command("exec", "Run a command with secrets in its environment.")
+	passphraseFlags := app.Command("passphrase", "Passphrase key manager operations.")
+	passphraseInitFlags := passphraseFlags.Command("init", "Add a passphrase-derived key to the template.")
 	kmsFlags := app.Command("kms", "AWS KMS-specific operations.")
 	kmsIDFlags := kmsFlags.Command("get-caller-identity", "Print the AWS credentials.")
 	kmsInitFlags := kmsFlags.Command("init", mustAsset("data/kmsinit.txt"))
@@ -65,7 +78,25 @@ func main() {
 	getCommand := cmd.NewGet(getFlags)
 	writeCommand := cmd.NewPut(putFlags)
 	listCommand := cmd.NewList(listFlags)
+	deleteCommand := cmd.NewDelete(deleteFlags)
+	editCommand := cmd.NewEdit(editFlags)
+	rekeyCommand := cmd.NewRekey(rekeyFlags)
+	rotateCommand := cmd.NewRotate(rotateFlags)
+	historyCommand := cmd.NewHistory(historyFlags)
+	rollbackCommand := cmd.NewRollback(rollbackFlags)
+	describeCommand := cmd.NewDescribe(describeFlags)
+	verifyCommand := cmd.NewVerify(verifyFlags)
+	migrateCommand := cmd.NewMigrate(migrateFlags)
+	syncSsmPushCommand := cmd.NewSsmPush(syncSsmPushFlags)
+	syncSsmPullCommand := cmd.NewSsmPull(syncSsmPullFlags)
 	exportCommand := cmd.NewExport(exportFlags)
+	importCommand := cmd.NewImport(importFlags)
+	renderCommand := cmd.NewRender(renderFlags)
+	agentCommand := cmd.NewAgent(agentFlags)
+	serveCommand := cmd.NewServe(serveFlags)
+	watchCommand := cmd.NewWatch(watchFlags)
+	execCommand := cmd.NewExec(execFlags)
+	passphraseInitCommand := cmd.NewPassphraseInit(passphraseInitFlags)
 	kmsIDCommand := awskms.KmsGetCallerIdentity{}
 	kmsEditKeyPolicy := awskms.NewKmsEditKeyPolicy(kmsEditKeyPolicyFlags)
 	kmsGrantsListCommand := awskms.NewKmsGrantsList(kmsGrantsListFlags)
@@ -84,6 +115,20 @@ func main() {
 		err = writeCommand.Run(ctx)
 	case listFlags.FullCommand():
 		err = listCommand.Run(ctx)
+	case deleteFlags.FullCommand():
+		err = deleteCommand.Run(ctx)
+	case editFlags.FullCommand():
+		err = editCommand.Run(ctx)
+	case rekeyFlags.FullCommand():
+		err = rekeyCommand.Run(ctx)
+	case rotateFlags.FullCommand():
+		err = rotateCommand.Run(ctx)
+	case historyFlags.FullCommand():
+		err = historyCommand.Run(ctx)
+	case rollbackFlags.FullCommand():
+		err = rollbackCommand.Run(ctx)
+	case passphraseInitFlags.FullCommand():
+		err = passphraseInitCommand.Run(ctx)
 	case kmsIDFlags.FullCommand():
 		err = kmsIDCommand.Run(ctx)
 	case kmsInitFlags.FullCommand():
@@ -98,8 +143,34 @@ func main() {
 		err = kmsDeprovisionCommand.Run(ctx)
 	case kmsGrantsRetireFlags.FullCommand():
 		err = kmsGrantsRetireCommand.Run(ctx)
+	case describeFlags.FullCommand():
+		err = describeCommand.Run(ctx)
+	case verifyFlags.FullCommand():
+		err = verifyCommand.Run(ctx)
+	case migrateFlags.FullCommand():
+		err = migrateCommand.Run(ctx)
+	case syncSsmPushFlags.FullCommand():
+		err = syncSsmPushCommand.Run(ctx)
+	case syncSsmPullFlags.FullCommand():
+		err = syncSsmPullCommand.Run(ctx)
 	case exportFlags.FullCommand():
 		err = exportCommand.Run(ctx)
+	case importFlags.FullCommand():
+		err = importCommand.Run(ctx)
+	case renderFlags.FullCommand():
+		err = renderCommand.Run(ctx)
+	case agentFlags.FullCommand():
+		err = agentCommand.Run(ctx)
+	case serveFlags.FullCommand():
+		err = serveCommand.Run(ctx)
+	case watchFlags.FullCommand():
+		err = watchCommand.Run(ctx)
+	case execFlags.FullCommand():
+		err = execCommand.Run(ctx)
+	}
+	var exitStatus cmd.ExitStatus
+	if errors.As(err, &exitStatus) {
+		os.Exit(int(exitStatus))
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
@@ -115,7 +186,7 @@ func main() {
 			}
 		}
 
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
 
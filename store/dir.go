@@ -0,0 +1,258 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dcoker/biscuit/internal/atomicfile"
+	"gopkg.in/yaml.v2"
+)
+
+// DirScheme is the scheme of stores kept in a directory with one YAML file per secret.
+const DirScheme = "dir"
+
+const (
+	dirStoreExtension = ".yml"
+	dirStoreLockName  = ".lock"
+)
+
+func init() {
+	registry[DirScheme] = func(location string, opts options) (Store, error) {
+		if location == "" {
+			return nil, errors.New("dir: missing directory")
+		}
+		return NewDirStore(location).WithLockTimeout(opts.lockTimeout), nil
+	}
+}
+
+var _ Store = DirStore{}
+
+// DirStore stores each entry of an EntryMap in its own YAML file, so that changes to different
+// secrets do not conflict when the directory is kept in version control. The template is stored
+// in _keys.yml. Writes are serialized across processes with an advisory lock on DIR/.lock.
+//
+// Names that differ only in case are rejected, because they would share a file on
+// case-insensitive filesystems.
+//
+// Each file is replaced atomically, but an Update that changes several entries is not: if it is
+// interrupted, some of the entries may have been written and others not.
+type DirStore struct {
+	dir         string
+	lockTimeout time.Duration
+}
+
+// NewDirStore constructs a DirStore for a specific directory.
+func NewDirStore(dir string) DirStore {
+	return DirStore{dir: dir, lockTimeout: DefaultLockTimeout}
+}
+
+// WithLockTimeout returns a copy of d that waits at most timeout to acquire the directory lock.
+func (d DirStore) WithLockTimeout(timeout time.Duration) DirStore {
+	d.lockTimeout = timeout
+	return d
+}
+
+// Get a value.
+func (d DirStore) Get(name string) (ValueList, error) {
+	return get(d, name)
+}
+
+// GetEntry returns a value along with its metadata.
+func (d DirStore) GetEntry(name string) (Entry, error) {
+	return getEntry(d, name)
+}
+
+// Put a value. Any existing metadata for the name is preserved.
+func (d DirStore) Put(name string, values ValueList) error {
+	return put(d, name, values)
+}
+
+// Delete removes the files of one or more values. If any of the named secrets do not exist,
+// ErrNameNotFound is returned and no files are removed.
+func (d DirStore) Delete(names ...string) error {
+	return deleteNames(d, names)
+}
+
+// List returns the names of the secrets in the directory.
+func (d DirStore) List() ([]string, error) {
+	return list(d)
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (d DirStore) GetKeyIds() ([]Key, error) {
+	return getKeyIds(d)
+}
+
+// GetAll returns all of the entries in the directory.
+func (d DirStore) GetAll() (EntryMap, error) {
+	entries, _, err := d.read()
+	return entries, err
+}
+
+// Update reads the directory, passes the entries to fn for modification, and writes back the
+// files of the entries that fn changed. The files of other entries are left as they are, even if
+// they were formatted by hand or by an older version of biscuit. The directory is created if
+// necessary.
+func (d DirStore) Update(fn func(entries EntryMap) error) error {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(filepath.Join(d.dir, dirStoreLockName), d.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, contents, err := d.read()
+	if err != nil {
+		return err
+	}
+	// fn may modify the entries in place, so compare against a copy decoded separately.
+	original, err := decodeEntries(contents)
+	if err != nil {
+		return err
+	}
+	if err := fn(entries); err != nil {
+		return err
+	}
+	if err := checkFileNames(entries); err != nil {
+		return err
+	}
+	for name, entry := range entries {
+		if previous, present := original[name]; present && reflect.DeepEqual(previous, entry) {
+			continue
+		}
+		output, err := yaml.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(d.filename(name), output); err != nil {
+			return err
+		}
+	}
+	for name := range contents {
+		if _, present := entries[name]; present {
+			continue
+		}
+		if err := os.Remove(d.filename(name)); err != nil {
+			return err
+		}
+	}
+	return atomicfile.SyncDir(d.dir)
+}
+
+// read returns the entries in the directory along with the file contents they were decoded from.
+func (d DirStore) read() (EntryMap, map[string][]byte, error) {
+	entries := make(EntryMap)
+	contents := make(map[string][]byte)
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		return entries, contents, fmt.Errorf("could not read directory %s: %w", d.dir, err)
+	}
+	for _, file := range files {
+		base := file.Name()
+		if file.IsDir() || strings.HasPrefix(base, ".") || !strings.HasSuffix(base, dirStoreExtension) {
+			continue
+		}
+		filename := filepath.Join(d.dir, base)
+		name, err := unescapeName(strings.TrimSuffix(base, dirStoreExtension))
+		if err != nil {
+			return entries, contents, fmt.Errorf("%s: %w", filename, err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return entries, contents, err
+		}
+		var entry Entry
+		if err := yaml.Unmarshal(data, &entry); err != nil {
+			return entries, contents, fmt.Errorf("%s: %w", filename, err)
+		}
+		entries[name] = entry
+		contents[name] = data
+	}
+	return entries, contents, nil
+}
+
+// decodeEntries decodes the contents of the files read from the directory.
+func decodeEntries(contents map[string][]byte) (EntryMap, error) {
+	entries := make(EntryMap, len(contents))
+	for name, data := range contents {
+		var entry Entry
+		if err := yaml.Unmarshal(data, &entry); err != nil {
+			return nil, err
+		}
+		entries[name] = entry
+	}
+	return entries, nil
+}
+
+// checkFileNames returns an error if any of the entries cannot be stored in a file of its own.
+// Names that differ only in case would share a file on case-insensitive filesystems, such as
+// the defaults on macOS and Windows, so they are rejected everywhere.
+func checkFileNames(entries EntryMap) error {
+	folded := make(map[string]string)
+	for name := range entries {
+		if name == "" {
+			return errors.New("dir: secret names cannot be empty")
+		}
+		key := strings.ToLower(escapeName(name))
+		if other, present := folded[key]; present {
+			if other > name {
+				other, name = name, other
+			}
+			return fmt.Errorf("dir: %s and %s differ only in case and cannot be stored in the same "+
+				"directory", other, name)
+		}
+		folded[key] = name
+	}
+	return nil
+}
+
+func (d DirStore) filename(name string) string {
+	return filepath.Join(d.dir, escapeName(name)+dirStoreExtension)
+}
+
+// escapeName maps a secret name to a file name that is valid on common filesystems. Bytes other
+// than ASCII letters, digits, '-', '_' and '.' are written as %XX, as is a leading '.' so that
+// secrets never become hidden files.
+func escapeName(name string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			escaped.WriteByte(c)
+		case c == '.' && i > 0:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func unescapeName(escaped string) (string, error) {
+	var name strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '%' {
+			name.WriteByte(escaped[i])
+			continue
+		}
+		if i+2 >= len(escaped) {
+			return "", fmt.Errorf("invalid escape sequence in file name %s", escaped)
+		}
+		c, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence in file name %s", escaped)
+		}
+		name.WriteByte(byte(c))
+		i += 2
+	}
+	return name.String(), nil
+}
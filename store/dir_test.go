@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirStore_Lifecycle(t *testing.T) {
+	parent, err := os.MkdirTemp("", "TestDirStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(parent)
+	dir := filepath.Join(parent, "secrets")
+
+	store, err := Open("dir://" + dir)
+	assert.NoError(t, err)
+	_, err = store.GetAll()
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	template := ValueList{{Key: Key{Algorithm: "none"}}}
+	k1put := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "YQ=="}}
+	assert.NoError(t, store.Put(KeyTemplateName, template))
+	assert.NoError(t, store.Put("k1", k1put))
+	assert.NoError(t, store.Put("db/.password", ValueList{}))
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.ElementsMatch(t, []string{".lock", "_keys.yml", "k1.yml", "db%2F.password.yml"}, names)
+
+	k1, err := store.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, k1put, k1)
+	listed, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db/.password", "k1"}, listed)
+	keys, err := store.GetKeyIds()
+	assert.NoError(t, err)
+	assert.Equal(t, []Key{{Algorithm: "none"}}, keys)
+
+	assert.NoError(t, store.Delete("db/.password"))
+	_, err = os.Stat(filepath.Join(dir, "db%2F.password.yml"))
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.True(t, errors.Is(store.Delete("missing"), ErrNameNotFound))
+}
+
+func TestDirStore_UnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewDirStore(dir)
+	assert.NoError(t, store.Put("k1", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "YQ=="}}))
+
+	// A file formatted by hand is not rewritten by changes to other secrets.
+	handEdited := []byte("# Edited by hand.\nvalues:\n  - algorithm: none\n    ciphertext: YQ==\n")
+	filename := filepath.Join(dir, "k1.yml")
+	assert.NoError(t, os.WriteFile(filename, handEdited, 0644))
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	contents, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, handEdited, contents)
+
+	assert.NoError(t, store.Put("k1", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "Yg=="}}))
+	contents, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NotEqual(t, handEdited, contents)
+}
+
+func TestDirStore_FileNames(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+	assert.NoError(t, store.Put("db_pass", ValueList{}))
+
+	err := store.Put("DB_PASS", ValueList{})
+	assert.EqualError(t, err, "dir: DB_PASS and db_pass differ only in case and cannot be stored "+
+		"in the same directory")
+	assert.Error(t, store.Put("", ValueList{}))
+
+	names, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db_pass"}, names)
+}
+
+func TestEscapeName(t *testing.T) {
+	for _, name := range []string{"simple", ".hidden", "a/b\\c:d", "café", "%41", "with space"} {
+		escaped := escapeName(name)
+		assert.NotContains(t, escaped[:1], ".")
+		assert.NotContains(t, escaped, "/")
+		unescaped, err := unescapeName(escaped)
+		assert.NoError(t, err)
+		assert.Equal(t, name, unescaped)
+	}
+	_, err := unescapeName("bad%2")
+	assert.Error(t, err)
+}
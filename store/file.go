@@ -0,0 +1,125 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/dcoker/biscuit/internal/atomicfile"
+	"gopkg.in/yaml.v2"
+)
+
+// FileScheme is the scheme of stores kept in a single YAML file on local disk. Locations without
+// a scheme are treated as files.
+const FileScheme = "file"
+
+func init() {
+	registry[FileScheme] = func(location string, opts options) (Store, error) {
+		if location == "" {
+			return nil, errors.New("file: missing filename")
+		}
+		return NewFileStore(location).WithLockTimeout(opts.lockTimeout), nil
+	}
+}
+
+var _ Store = FileStore{}
+
+// FileStore stores an EntryMap in a YAML file on local disk. Writes are serialized across
+// processes with an advisory lock on a sibling file named FILE.lock.
+type FileStore struct {
+	filename    string
+	lockTimeout time.Duration
+}
+
+// NewFileStore constructs a FileStore for a specific filename.
+func NewFileStore(filename string) FileStore {
+	return FileStore{filename: filename, lockTimeout: DefaultLockTimeout}
+}
+
+// WithLockTimeout returns a copy of f that waits at most timeout to acquire the file lock.
+func (f FileStore) WithLockTimeout(timeout time.Duration) FileStore {
+	f.lockTimeout = timeout
+	return f
+}
+
+// Get a value.
+func (f FileStore) Get(name string) (ValueList, error) {
+	return get(f, name)
+}
+
+// GetEntry returns a value along with its metadata.
+func (f FileStore) GetEntry(name string) (Entry, error) {
+	return getEntry(f, name)
+}
+
+// Put a value. Any existing metadata for the name is preserved.
+func (f FileStore) Put(name string, values ValueList) error {
+	return put(f, name, values)
+}
+
+// Delete removes one or more values in a single write. If any of the named secrets do not
+// exist, ErrNameNotFound is returned and the file is left unchanged.
+func (f FileStore) Delete(names ...string) error {
+	return deleteNames(f, names)
+}
+
+// List returns the names of the secrets in the file.
+func (f FileStore) List() ([]string, error) {
+	return list(f)
+}
+
+// Update reads the file, passes the entries to fn for modification, and writes the result
+// back. The file is locked for the duration so that concurrent writers do not lose each
+// other's changes. If the file does not exist, fn receives an empty EntryMap. If fn returns an
+// error, the file is not modified.
+func (f FileStore) Update(fn func(entries EntryMap) error) error {
+	unlock, err := lockFile(f.filename+".lock", f.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := f.GetAll()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := fn(entries); err != nil {
+		return err
+	}
+	return f.write(entries)
+}
+
+// write atomically replaces the file with entries.
+func (f FileStore) write(entries EntryMap) error {
+	output, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.filename, output)
+}
+
+// writeFileAtomic replaces filename with contents, preserving the permissions of an existing file.
+func writeFileAtomic(filename string, contents []byte) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return atomicfile.WriteFile(filename, contents, mode)
+}
+
+// GetAll returns all of the entries in the file.
+func (f FileStore) GetAll() (EntryMap, error) {
+	contents, err := os.ReadFile(f.filename)
+	entries := make(EntryMap)
+	if err != nil {
+		return entries, fmt.Errorf("could not read file %s: %w", f.filename, err)
+	}
+	return entries, yaml.Unmarshal(contents, entries)
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (f FileStore) GetKeyIds() ([]Key, error) {
+	return getKeyIds(f)
+}
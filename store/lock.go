@@ -0,0 +1,47 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// DefaultLockTimeout is how long a FileStore waits to acquire the lock before giving up.
+const DefaultLockTimeout = 10 * time.Second
+
+// ErrLockTimeout is returned when the lock on a file could not be acquired in time.
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// lockFile acquires an exclusive advisory lock on filename, creating it if necessary. The
+// returned function releases the lock.
+func lockFile(filename string, timeout time.Duration) (func() error, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file %s: %w", filename, err)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not lock %s: %w", filename, err)
+		}
+		if locked {
+			return func() error {
+				if err := unlock(f); err != nil {
+					f.Close()
+					return err
+				}
+				return f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w after %s", filename, ErrLockTimeout, timeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"gopkg.in/yaml.v2"
+)
+
+// S3Scheme is the scheme of stores kept in a single YAML object in Amazon S3.
+const S3Scheme = "s3"
+
+// ErrConflict is returned when a write to a store without locks kept failing because of
+// concurrent writers.
+var ErrConflict = errors.New("gave up after repeated concurrent modifications")
+
+func init() {
+	registry[S3Scheme] = func(location string, opts options) (Store, error) {
+		return newS3Store(context.Background(), location, opts)
+	}
+}
+
+var _ Store = S3Store{}
+
+// S3Store stores an EntryMap in a YAML object in S3, in the same format as FileStore. S3 has no
+// locks, so Update writes conditionally on the object's ETag, and starts over if another process
+// wrote the object in the meantime.
+//
+// The location has the form BUCKET/KEY[?OPTIONS], where OPTIONS may include:
+//
+//	region=REGION        the region of the bucket, if not the default region
+//	sse=AES256|aws:kms   server-side encryption to request when writing the object
+//	sse-kms-key-id=KEY   the KMS key to use for server-side encryption; implies sse=aws:kms
+//
+// If AWS_ENDPOINT is set, requests are sent there using path-style addressing.
+type S3Store struct {
+	client      *s3.Client
+	bucket      string
+	key         string
+	sse         types.ServerSideEncryption
+	sseKmsKeyID string
+	// conflictTimeout bounds how long Update keeps retrying after conflicting writes.
+	conflictTimeout time.Duration
+}
+
+func newS3Store(ctx context.Context, location string, opts options) (S3Store, error) {
+	parsed, err := url.Parse(S3Scheme + "://" + location)
+	if err != nil {
+		return S3Store{}, fmt.Errorf("s3: %w", err)
+	}
+	store := S3Store{
+		bucket:          parsed.Host,
+		key:             strings.TrimPrefix(parsed.Path, "/"),
+		conflictTimeout: opts.lockTimeout,
+	}
+	if store.bucket == "" || store.key == "" {
+		return S3Store{}, errors.New("s3: location must have the form s3://BUCKET/KEY")
+	}
+
+	var configOptions []func(*config.LoadOptions) error
+	for name, values := range parsed.Query() {
+		value := values[len(values)-1]
+		switch name {
+		case "region":
+			configOptions = append(configOptions, config.WithRegion(value))
+		case "sse":
+			store.sse = types.ServerSideEncryption(value)
+			if store.sse != types.ServerSideEncryptionAes256 && store.sse != types.ServerSideEncryptionAwsKms {
+				return S3Store{}, fmt.Errorf("s3: unsupported server-side encryption '%s'", value)
+			}
+		case "sse-kms-key-id":
+			store.sseKmsKeyID = value
+		default:
+			return S3Store{}, fmt.Errorf("s3: unknown option '%s'", name)
+		}
+	}
+	if store.sseKmsKeyID != "" {
+		if store.sse == types.ServerSideEncryptionAes256 {
+			return S3Store{}, errors.New("s3: sse-kms-key-id requires sse=aws:kms")
+		}
+		store.sse = types.ServerSideEncryptionAwsKms
+	}
+
+	cfg, err := myAWS.NewConfig(ctx, configOptions...)
+	if err != nil {
+		return S3Store{}, err
+	}
+	store.client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// Local S3 stand-ins generally do not support virtual-hosted buckets.
+		o.UsePathStyle = os.Getenv("AWS_ENDPOINT") != ""
+	})
+	return store, nil
+}
+
+func (s S3Store) String() string {
+	return S3Scheme + "://" + s.bucket + "/" + s.key
+}
+
+// Get a value.
+func (s S3Store) Get(name string) (ValueList, error) {
+	return get(s, name)
+}
+
+// GetEntry returns a value along with its metadata.
+func (s S3Store) GetEntry(name string) (Entry, error) {
+	return getEntry(s, name)
+}
+
+// Put a value. Any existing metadata for the name is preserved.
+func (s S3Store) Put(name string, values ValueList) error {
+	return put(s, name, values)
+}
+
+// Delete removes one or more values in a single write. If any of the named secrets do not
+// exist, ErrNameNotFound is returned and the object is left unchanged.
+func (s S3Store) Delete(names ...string) error {
+	return deleteNames(s, names)
+}
+
+// List returns the names of the secrets in the object.
+func (s S3Store) List() ([]string, error) {
+	return list(s)
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (s S3Store) GetKeyIds() ([]Key, error) {
+	return getKeyIds(s)
+}
+
+// GetAll returns all of the entries in the object.
+func (s S3Store) GetAll() (EntryMap, error) {
+	entries, _, err := s.read(context.Background())
+	return entries, err
+}
+
+// Update reads the object, passes the entries to fn for modification, and writes the result back
+// if the object has not changed since it was read. Otherwise, it tries again with the new
+// contents of the object, for up to the lock timeout. fn may therefore be called more than once.
+func (s S3Store) Update(fn func(entries EntryMap) error) error {
+	ctx := context.Background()
+	deadline := time.Now().Add(s.conflictTimeout)
+	for {
+		entries, etag, err := s.read(ctx)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := fn(entries); err != nil {
+			return err
+		}
+		output, err := yaml.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		err = s.write(ctx, output, etag)
+		if !isS3Conflict(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s: %w", s, ErrConflict)
+		}
+		time.Sleep(lockRetryInterval + time.Duration(rand.Int63n(int64(lockRetryInterval))))
+	}
+}
+
+// read returns the entries in the object and its ETag. If the object does not exist, the ETag is
+// nil and the error wraps fs.ErrNotExist.
+func (s S3Store) read(ctx context.Context) (EntryMap, *string, error) {
+	entries := make(EntryMap)
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &s.key,
+	})
+	var responseError *awshttp.ResponseError
+	if errors.As(err, &responseError) && responseError.HTTPStatusCode() == http.StatusNotFound {
+		return entries, nil, fmt.Errorf("could not read %s: %w", s, fs.ErrNotExist)
+	}
+	if err != nil {
+		return entries, nil, fmt.Errorf("could not read %s: %w", s, err)
+	}
+	defer output.Body.Close()
+	contents, err := io.ReadAll(output.Body)
+	if err != nil {
+		return entries, nil, fmt.Errorf("could not read %s: %w", s, err)
+	}
+	return entries, output.ETag, yaml.Unmarshal(contents, entries)
+}
+
+// write replaces the object with contents, provided that its ETag is still etag, or that it still
+// does not exist if etag is nil.
+func (s S3Store) write(ctx context.Context, contents []byte, etag *string) error {
+	condition := withHeader("If-None-Match", "*")
+	if etag != nil {
+		condition = withHeader("If-Match", *etag)
+	}
+	input := &s3.PutObjectInput{
+		Bucket:               &s.bucket,
+		Key:                  &s.key,
+		Body:                 bytes.NewReader(contents),
+		ContentType:          aws.String("application/x-yaml"),
+		ServerSideEncryption: s.sse,
+	}
+	if s.sseKmsKeyID != "" {
+		input.SSEKMSKeyId = &s.sseKmsKeyID
+	}
+	_, err := s.client.PutObject(ctx, input, condition)
+	return err
+}
+
+// isS3Conflict returns true if err indicates that a conditional write failed because the object
+// was modified concurrently.
+func isS3Conflict(err error) bool {
+	var responseError *awshttp.ResponseError
+	if !errors.As(err, &responseError) {
+		return false
+	}
+	status := responseError.HTTPStatusCode()
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
+
+// withHeader adds an HTTP header to a request. The SDK does not model the conditional headers of
+// PutObject.
+func withHeader(name, value string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Build.Add(middleware.BuildMiddlewareFunc("biscuit"+name,
+				func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (
+					middleware.BuildOutput, middleware.Metadata, error) {
+					if request, ok := in.Request.(*smithyhttp.Request); ok {
+						request.Header.Set(name, value)
+					}
+					return next.HandleBuild(ctx, in)
+				}), middleware.After)
+		})
+	}
+}
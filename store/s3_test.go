@@ -0,0 +1,177 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 implements enough of the S3 API for S3Store: GetObject and conditional PutObject with
+// path-style addressing.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	etags   map[string]string
+	headers map[string]http.Header
+	writes  int
+	onPut   func()
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, etags: map[string]string{}, headers: map[string]http.Header{}}
+}
+
+func (f *fakeS3) store(path string, contents []byte) {
+	f.writes++
+	f.objects[path] = contents
+	f.etags[path] = fmt.Sprintf(`"%d"`, f.writes)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	etag, present := f.etags[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet:
+		if !present {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(f.objects[r.URL.Path])
+	case http.MethodPut:
+		if f.onPut != nil {
+			onPut := f.onPut
+			f.onPut = nil
+			onPut()
+			etag, present = f.etags[r.URL.Path]
+		}
+		if (r.Header.Get("If-None-Match") == "*" && present) ||
+			(r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != etag) {
+			writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		contents, _ := io.ReadAll(r.Body)
+		f.store(r.URL.Path, contents)
+		f.headers[r.URL.Path] = r.Header.Clone()
+		w.Header().Set("ETag", f.etags[r.URL.Path])
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func withFakeS3(t *testing.T) *fakeS3 {
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	for name, value := range map[string]string{
+		"AWS_ENDPOINT":          server.URL,
+		"AWS_REGION":            "us-west-2",
+		"AWS_ACCESS_KEY_ID":     "test",
+		"AWS_SECRET_ACCESS_KEY": "test",
+	} {
+		previous, set := os.LookupEnv(name)
+		os.Setenv(name, value)
+		name := name
+		t.Cleanup(func() {
+			if set {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+	return fake
+}
+
+func TestS3Store_Lifecycle(t *testing.T) {
+	fake := withFakeS3(t)
+	store, err := Open("s3://bucket/path/secrets.yml?sse=aws:kms&sse-kms-key-id=alias/s3")
+	assert.NoError(t, err)
+
+	_, err = store.GetAll()
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	k1put := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "YQ=="}}
+	assert.NoError(t, store.Put("k1", k1put))
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	k1, err := store.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, k1put, k1)
+	names, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"k1", "k2"}, names)
+
+	headers := fake.headers["/bucket/path/secrets.yml"]
+	assert.Equal(t, "aws:kms", headers.Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "alias/s3", headers.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	assert.Equal(t, `"1"`, headers.Get("If-Match"))
+}
+
+func TestS3Store_concurrentUpdate(t *testing.T) {
+	fake := withFakeS3(t)
+	store, err := Open("s3://bucket/secrets.yml")
+	assert.NoError(t, err)
+	other, err := Open("s3://bucket/secrets.yml")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put("k1", ValueList{}))
+
+	// Another writer sneaks in between our read and our write.
+	fake.onPut = func() {
+		fake.mu.Unlock()
+		defer fake.mu.Lock()
+		assert.NoError(t, other.Put("k2", ValueList{}))
+	}
+	calls := 0
+	assert.NoError(t, store.Update(func(entries EntryMap) error {
+		calls++
+		entries["k3"] = Entry{}
+		return nil
+	}))
+	assert.Equal(t, 2, calls)
+	names, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"k1", "k2", "k3"}, names)
+}
+
+func TestS3Store_conflictTimeout(t *testing.T) {
+	fake := withFakeS3(t)
+	store, err := Open("s3://bucket/secrets.yml", LockTimeout(0))
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put("k1", ValueList{}))
+	fake.onPut = func() {
+		fake.store("/bucket/secrets.yml", []byte("{}"))
+	}
+	err = store.Put("k2", ValueList{})
+	assert.True(t, errors.Is(err, ErrConflict))
+}
+
+func TestS3Store_options(t *testing.T) {
+	withFakeS3(t)
+	for _, location := range []string{
+		"s3://bucket",
+		"s3://bucket/key?sse=rot13",
+		"s3://bucket/key?sse=AES256&sse-kms-key-id=alias/s3",
+		"s3://bucket/key?colour=blue",
+	} {
+		_, err := Open(location)
+		assert.Error(t, err, location)
+	}
+	_, err := Open("s3://bucket/key?region=eu-west-1&sse=AES256", LockTimeout(time.Second))
+	assert.NoError(t, err)
+}
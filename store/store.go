@@ -1,113 +1,394 @@
 package store
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
-	"io/fs"
-	"os"
-
-	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+	"time"
 )
 
 // KeyTemplateName is the name of the value that configures the default set of key settings.
 const KeyTemplateName = "_keys"
 
-var (
-	errNoTemplateEntry = errors.New("Template not found. Please specify a key ID with --key-id, or add a " +
-		KeyTemplateName + " entry.")
+// DefaultHistoryLimit is the number of previous versions kept for each secret when the template
+// does not set history_limit.
+const DefaultHistoryLimit = 5
+
+const (
+	// FormatLegacy Values were encrypted without associated data.
+	FormatLegacy = iota
+	// FormatAssociatedData Values bind the secret name and key ID to the ciphertext as associated
+	// data, so that a ciphertext copied to another name or key fails to decrypt.
+	FormatAssociatedData
+)
 
-	// ErrNameNotFound is returned by Get if the named secret does not exist.
+var (
+	// ErrNoTemplate is returned by GetKeyIds if there is no template entry.
+	ErrNoTemplate = errors.New(KeyTemplateName + " entry not found")
+	// ErrNameNotFound matches, with errors.Is, the *NameNotFoundError returned when a secret does
+	// not exist.
 	ErrNameNotFound = errors.New("name not found")
+	// ErrVersionNotFound is returned by Entry.Revision if the version is not in the history.
+	ErrVersionNotFound = errors.New("version not found")
 )
 
-// FileStore stores an EntryMap in a YAML file on local disk.
-type FileStore string
+// NameNotFoundError is returned when the named secret does not exist.
+type NameNotFoundError struct {
+	Name string
+}
 
-// EntryMap represents the contents of the file.
-type EntryMap map[string]ValueList
+func (e *NameNotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, ErrNameNotFound)
+}
 
-// ValueList represents a list of Values.
-type ValueList []Value
+// Is makes errors.Is(err, ErrNameNotFound) true.
+func (e *NameNotFoundError) Is(target error) bool {
+	return target == ErrNameNotFound
+}
 
-// FilterByKeyManager returns a new ValueList consisting only of the Values corresponding to a specific key manager.
-func (v ValueList) FilterByKeyManager(manager string) ValueList {
-	var results ValueList
-	for _, value := range []Value(v) {
-		if value.KeyManager == manager {
-			results = append(results, value)
-		}
+// Store holds a set of secrets. Implementations are selected by the scheme of the location
+// passed to Open.
+type Store interface {
+	// Get returns the current values of a secret, or ErrNameNotFound.
+	Get(name string) (ValueList, error)
+	// GetEntry returns a secret with its metadata and history, or ErrNameNotFound.
+	GetEntry(name string) (Entry, error)
+	// Put replaces the values of a secret, preserving any metadata.
+	Put(name string, values ValueList) error
+	// Delete removes secrets in a single write. If any of them do not exist, ErrNameNotFound is
+	// returned and nothing is removed.
+	Delete(names ...string) error
+	// GetAll returns every entry, including the template. If the store does not exist, the
+	// error wraps fs.ErrNotExist.
+	GetAll() (EntryMap, error)
+	// GetKeyIds returns the keys specified by the template entry.
+	GetKeyIds() ([]Key, error)
+	// List returns the sorted names of the secrets, excluding the template.
+	List() ([]string, error)
+	// Update passes the entries to fn for modification and saves the result, ensuring that
+	// concurrent writers do not lose each other's changes. If the store does not exist, fn
+	// receives an empty EntryMap. If fn returns an error, nothing is saved.
+	Update(fn func(entries EntryMap) error) error
+}
+
+var registry = make(map[string]func(location string, opts options) (Store, error))
+
+type options struct {
+	lockTimeout time.Duration
+}
+
+// Option configures a Store returned by Open.
+type Option func(*options)
+
+// LockTimeout sets how long a write waits for concurrent writers to finish. The default is
+// DefaultLockTimeout.
+func LockTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.lockTimeout = timeout
 	}
-	return results
 }
 
-// NewFileStore constructs a FileStore for a specific filename.
-func NewFileStore(filename string) FileStore {
-	return FileStore(filename)
+type errUnsupportedScheme struct {
+	scheme string
 }
 
-// Get a value.
-func (f FileStore) Get(name string) (ValueList, error) {
-	entries, err := f.GetAll()
-	if err != nil {
-		return []Value{}, err
+func (e *errUnsupportedScheme) Error() string {
+	msg := fmt.Sprintf("unsupported store scheme '%s' (supported: %s)", e.scheme,
+		strings.Join(GetSchemes(), ", "))
+	if e.scheme == "ssm" {
+		// SSM cannot make a write conditional on what was read, so it is a sync target instead.
+		msg += "; use biscuit sync ssm to copy secrets to and from SSM Parameter Store"
+	}
+	return msg
+}
+
+// Open returns the Store at location, which is either a URL of the form SCHEME://PATH or the name
+// of a local file.
+func Open(location string, opts ...Option) (Store, error) {
+	o := options{lockTimeout: DefaultLockTimeout}
+	for _, opt := range opts {
+		opt(&o)
 	}
-	value, present := entries[name]
+	scheme, path := FileScheme, location
+	if i := strings.Index(location, "://"); i >= 0 {
+		scheme, path = location[:i], location[i+len("://"):]
+	}
+	constructor, present := registry[scheme]
 	if !present {
-		return []Value{}, ErrNameNotFound
+		return nil, &errUnsupportedScheme{scheme}
 	}
-	return value, nil
+	return constructor(path, o)
 }
 
-// Put a value.
-func (f FileStore) Put(name string, values ValueList) error {
-	entries, err := f.GetAll()
-	if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return err
+// GetSchemes returns the schemes of the registered Store implementations.
+func GetSchemes() []string {
+	var schemes []string
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
 	}
-	entries[name] = values
+	sort.Strings(schemes)
+	return schemes
+}
+
+// entrySource is the part of a Store that differs between implementations. The remaining
+// methods can be expressed in terms of it.
+type entrySource interface {
+	GetAll() (EntryMap, error)
+	Update(fn func(entries EntryMap) error) error
+}
 
-	output, err := yaml.Marshal(entries)
+func get(s entrySource, name string) (ValueList, error) {
+	entry, err := getEntry(s, name)
 	if err != nil {
-		return err
+		return []Value{}, err
 	}
+	return entry.Values, nil
+}
 
-	// poor attempt at atomic file write
-	tempfile := string(f) + ".tmp"
-	if err := os.WriteFile(tempfile, output, 0644); err != nil {
-		return err
+func getEntry(s entrySource, name string) (Entry, error) {
+	entries, err := s.GetAll()
+	if err != nil {
+		return Entry{}, err
+	}
+	entry, present := entries[name]
+	if !present {
+		return Entry{}, &NameNotFoundError{Name: name}
 	}
-	return os.Rename(tempfile, string(f))
+	return entry, nil
 }
 
-// GetAll returns all of the entries in the file.
-func (f FileStore) GetAll() (EntryMap, error) {
-	contents, err := os.ReadFile(string(f))
-	entries := make(EntryMap)
+func put(s entrySource, name string, values ValueList) error {
+	return s.Update(func(entries EntryMap) error {
+		entry := entries[name]
+		entry.Values = values
+		entries[name] = entry
+		return nil
+	})
+}
+
+func deleteNames(s entrySource, names []string) error {
+	return s.Update(func(entries EntryMap) error {
+		for _, name := range names {
+			if _, present := entries[name]; !present {
+				return &NameNotFoundError{Name: name}
+			}
+			delete(entries, name)
+		}
+		return nil
+	})
+}
+
+func list(s entrySource) ([]string, error) {
+	entries, err := s.GetAll()
 	if err != nil {
-		return entries, fmt.Errorf("could not read file %s: %w", f, err)
+		return nil, err
+	}
+	var names []string
+	for name := range entries {
+		if name != KeyTemplateName {
+			names = append(names, name)
+		}
 	}
-	return entries, yaml.Unmarshal(contents, entries)
+	sort.Strings(names)
+	return names, nil
 }
 
-// GetKeyIds returns the keys specified by the template entry.
-func (f FileStore) GetKeyIds() ([]Key, error) {
-	entries, err := f.GetAll()
+func getKeyIds(s entrySource) ([]Key, error) {
+	entries, err := s.GetAll()
 	if err != nil {
 		return nil, err
 	}
 	template, present := entries[KeyTemplateName]
 	if !present {
-		return nil, errNoTemplateEntry
+		return nil, ErrNoTemplate
 	}
 
 	var keys []Key
-	for _, entry := range template {
-		keys = append(keys, entry.Key)
+	for _, value := range template.Values {
+		keys = append(keys, value.Key)
 	}
 	return keys, nil
 }
 
+// EntryMap represents the contents of the file.
+type EntryMap map[string]Entry
+
+// HistoryLimit returns the number of previous versions to keep for each secret, as configured by
+// the template entry.
+func (m EntryMap) HistoryLimit() int {
+	if limit := m[KeyTemplateName].HistoryLimit; limit != nil {
+		if *limit < 0 {
+			return 0
+		}
+		return *limit
+	}
+	return DefaultHistoryLimit
+}
+
+// Entry is a named secret: its encrypted Values, and optional unencrypted Metadata. Entries
+// without Metadata or History are stored as a plain list of Values, as they were before either
+// existed.
+type Entry struct {
+	Metadata *Metadata
+	Values   ValueList
+	// History holds previous versions of the secret, most recent first.
+	History []Revision
+	// HistoryLimit is only meaningful on the template entry. See EntryMap.HistoryLimit.
+	HistoryLimit *int
+}
+
+// Revision is a previous version of an Entry.
+type Revision struct {
+	Metadata *Metadata `yaml:"metadata,omitempty"`
+	Values   ValueList `yaml:"values"`
+}
+
+// Version returns the version number of the current Values. Entries written before versions were
+// recorded are version 1.
+func (e *Entry) Version() int {
+	if e.Metadata == nil || e.Metadata.Version == 0 {
+		return 1
+	}
+	return e.Metadata.Version
+}
+
+// Revision returns the Values of the given version, which may be the current version or one in
+// the History.
+func (e *Entry) Revision(version int) (ValueList, error) {
+	if version == e.Version() {
+		return e.Values, nil
+	}
+	for _, revision := range e.History {
+		if revision.Metadata != nil && revision.Metadata.Version == version {
+			return revision.Values, nil
+		}
+	}
+	return nil, fmt.Errorf("%d: %w", version, ErrVersionNotFound)
+}
+
+// Supersede replaces the Values of e with a new version. The current Values and Metadata are
+// moved to the History, of which at most limit revisions are kept. The caller is responsible for
+// updating the remaining Metadata.
+func (e *Entry) Supersede(values ValueList, limit int) {
+	version := 1
+	if len(e.Values) > 0 {
+		version = e.Version() + 1
+		previous := Revision{Metadata: e.Metadata.clone(), Values: e.Values}
+		previous.Metadata.Version = e.Version()
+		e.History = append([]Revision{previous}, e.History...)
+	}
+	if len(e.History) > limit {
+		e.History = e.History[:limit]
+	}
+	if len(e.History) == 0 {
+		e.History = nil
+	}
+	e.Metadata = e.Metadata.clone()
+	e.Metadata.Version = version
+	e.Values = values
+}
+
+// SupersedeBy replaces the Values of e with a new version written by updatedBy, as Supersede does,
+// and records when the entry was updated.
+func (e *Entry) SupersedeBy(values ValueList, limit int, updatedBy string) {
+	e.Supersede(values, limit)
+	now := time.Now().UTC().Truncate(time.Second)
+	// Entries written before metadata existed have an unknown creation time; leave it unset
+	// rather than claim they were created now.
+	if e.Metadata.CreatedAt == nil && e.Version() == 1 {
+		e.Metadata.CreatedAt = &now
+	}
+	e.Metadata.UpdatedAt = &now
+	e.Metadata.UpdatedBy = updatedBy
+}
+
+// Metadata describes a secret. It is stored in plaintext and is not authenticated.
+type Metadata struct {
+	Description string            `yaml:"description,omitempty"`
+	Owner       string            `yaml:"owner,omitempty"`
+	Tags        map[string]string `yaml:"tags,omitempty"`
+	CreatedAt   *time.Time        `yaml:"created_at,omitempty"`
+	UpdatedAt   *time.Time        `yaml:"updated_at,omitempty"`
+	UpdatedBy   string            `yaml:"updated_by,omitempty"`
+	// Version counts the writes of new values to the secret, starting at 1.
+	Version int `yaml:"version,omitempty"`
+}
+
+// clone returns a deep copy of m, or an empty Metadata if m is nil.
+func (m *Metadata) clone() *Metadata {
+	if m == nil {
+		return &Metadata{}
+	}
+	clone := *m
+	if m.Tags != nil {
+		clone.Tags = make(map[string]string, len(m.Tags))
+		for key, value := range m.Tags {
+			clone.Tags[key] = value
+		}
+	}
+	return &clone
+}
+
+// entryDocument is the YAML form of an Entry that has more than just Values.
+type entryDocument struct {
+	HistoryLimit *int       `yaml:"history_limit,omitempty"`
+	Metadata     *Metadata  `yaml:"metadata,omitempty"`
+	Values       ValueList  `yaml:"values"`
+	History      []Revision `yaml:"history,omitempty"`
+}
+
+// MarshalYAML writes the Entry as a list of Values if it has nothing else, or as an
+// entryDocument otherwise.
+func (e Entry) MarshalYAML() (interface{}, error) {
+	if e.Metadata == nil && len(e.History) == 0 && e.HistoryLimit == nil {
+		return e.Values, nil
+	}
+	return entryDocument{
+		HistoryLimit: e.HistoryLimit,
+		Metadata:     e.Metadata,
+		Values:       e.Values,
+		History:      e.History,
+	}, nil
+}
+
+// UnmarshalYAML reads either form written by MarshalYAML.
+func (e *Entry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values ValueList
+	if err := unmarshal(&values); err == nil {
+		*e = Entry{Values: values}
+		return nil
+	}
+	var document entryDocument
+	if err := unmarshal(&document); err != nil {
+		return err
+	}
+	*e = Entry{
+		Metadata:     document.Metadata,
+		Values:       document.Values,
+		History:      document.History,
+		HistoryLimit: document.HistoryLimit,
+	}
+	return nil
+}
+
+// ValueList represents a list of Values.
+type ValueList []Value
+
+// FilterByKeyManager returns a new ValueList consisting only of the Values corresponding to a specific key manager.
+func (v ValueList) FilterByKeyManager(manager string) ValueList {
+	var results ValueList
+	for _, value := range []Value(v) {
+		if value.KeyManager == manager {
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
 // Key defines key and crypto settings for a particular value.
 type Key struct {
 	// KeyID is the key that a value is encrypted under. This identifies which key the
@@ -129,6 +410,25 @@ type Value struct {
 	KeyCiphertext string `yaml:"key_ciphertext,omitempty"`
 	// Ciphertext is the plaintext encrypted with the ephemeral key.
 	Ciphertext string `yaml:"ciphertext,omitempty"`
+	// FormatVersion indicates how Ciphertext was produced. Values written before this field
+	// existed have the zero value, FormatLegacy.
+	FormatVersion int `yaml:"format_version,omitempty"`
+	// RotatedAt is when the data key protecting this Value was generated. It is not set for
+	// Values written before this field existed, or for algorithms that do not use a key.
+	RotatedAt *time.Time `yaml:"rotated_at,omitempty"`
+}
+
+// AssociatedData returns the data bound to the ciphertext of a Value with the given name. Each
+// field is length-prefixed so that distinct (name, key ID) pairs never encode identically.
+func (v *Value) AssociatedData(name string) []byte {
+	var buf bytes.Buffer
+	for _, field := range []string{name, v.KeyID} {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(field)))
+		buf.Write(length[:])
+		buf.WriteString(field)
+	}
+	return buf.Bytes()
 }
 
 // GetKeyCiphertext returns the base64-decoded encrypted key.
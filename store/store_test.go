@@ -5,7 +5,11 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"fmt"
 
@@ -41,10 +45,10 @@ func TestStore_Lifecycle(t *testing.T) {
 	entries, err := store.GetAll()
 	assert.NoError(t, err)
 	assert.Len(t, entries, 1)
-	assert.Equal(t, k1put, entries["k1"])
+	assert.Equal(t, k1put, entries["k1"].Values)
 	k1actual, err := store.Get("k1")
 	assert.NoError(t, err)
-	assert.Equal(t, entries["k1"], k1actual)
+	assert.Equal(t, entries["k1"].Values, k1actual)
 
 	k2put := ValueList{{
 		Key: Key{
@@ -60,8 +64,8 @@ func TestStore_Lifecycle(t *testing.T) {
 	entries, err = store.GetAll()
 	assert.NoError(t, err)
 	assert.Len(t, entries, 2)
-	assert.Equal(t, k1put, entries["k1"])
-	assert.Equal(t, k2put, entries["k2"])
+	assert.Equal(t, k1put, entries["k1"].Values)
+	assert.Equal(t, k2put, entries["k2"].Values)
 }
 
 func TestStore_fileDoesNotExist(t *testing.T) {
@@ -83,6 +87,192 @@ func TestStore_writingCreatesFile(t *testing.T) {
 	assert.Len(t, contents, 1)
 }
 
+func TestStore_Delete(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "TestStore")
+	defer mustRemove(tmpfile.Name())
+	assert.NoError(t, err)
+	store := NewFileStore(tmpfile.Name())
+	assert.NoError(t, store.Put("k1", ValueList{}))
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	assert.NoError(t, store.Put("k3", ValueList{}))
+
+	assert.NoError(t, store.Delete("k1", "k3"))
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Contains(t, entries, "k2")
+
+	// Deleting a missing name fails without modifying the file.
+	err = store.Delete("k2", "k1")
+	assert.True(t, errors.Is(err, ErrNameNotFound))
+	var notFound *NameNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "k1", notFound.Name)
+	entries, err = store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestStore_concurrentPuts(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, NewFileStore(filename).Put(fmt.Sprintf("k%d", i), ValueList{}))
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := NewFileStore(filename).GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, writers)
+	leftovers, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func TestStore_lockTimeout(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+
+	unlock, err := lockFile(filename+".lock", time.Second)
+	assert.NoError(t, err)
+	err = NewFileStore(filename).WithLockTimeout(100*time.Millisecond).Put("k1", ValueList{})
+	assert.True(t, errors.Is(err, ErrLockTimeout))
+	assert.NoError(t, unlock())
+	assert.NoError(t, NewFileStore(filename).Put("k1", ValueList{}))
+}
+
+func TestValue_AssociatedData(t *testing.T) {
+	left := Value{Key: Key{KeyID: "bc"}}
+	right := Value{Key: Key{KeyID: "c"}}
+	assert.NotEqual(t, left.AssociatedData("a"), right.AssociatedData("ab"))
+	assert.Equal(t, left.AssociatedData("a"), left.AssociatedData("a"))
+}
+
+func TestEntry_YAML(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := filepath.Join(dir, "secrets.yml")
+
+	// Files written before metadata existed store each entry as a list of values.
+	legacy := "k1:\n- algorithm: none\n  ciphertext: YQ==\n"
+	assert.NoError(t, os.WriteFile(filename, []byte(legacy), 0644))
+	store := NewFileStore(filename)
+	entry, err := store.GetEntry("k1")
+	assert.NoError(t, err)
+	assert.Nil(t, entry.Metadata)
+	assert.Equal(t, ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "YQ=="}}, entry.Values)
+
+	createdAt := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.Update(func(entries EntryMap) error {
+		entry := entries["k1"]
+		entry.Metadata = &Metadata{Owner: "ops", Tags: map[string]string{"env": "prod"}, CreatedAt: &createdAt}
+		entries["k1"] = entry
+		return nil
+	}))
+	entry, err = store.GetEntry("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", entry.Metadata.Owner)
+	assert.Equal(t, "prod", entry.Metadata.Tags["env"])
+	assert.True(t, createdAt.Equal(*entry.Metadata.CreatedAt))
+	assert.Len(t, entry.Values, 1)
+
+	// Put replaces the values but keeps the metadata.
+	assert.NoError(t, store.Put("k1", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "Yg=="}}))
+	entry, err = store.GetEntry("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", entry.Metadata.Owner)
+	assert.Equal(t, "Yg==", entry.Values[0].Ciphertext)
+}
+
+func TestEntry_Supersede(t *testing.T) {
+	var entry Entry
+	for i := 1; i <= 4; i++ {
+		entry.Supersede(ValueList{{Ciphertext: fmt.Sprint(i)}}, 2)
+		entry.Metadata.Tags = map[string]string{"v": fmt.Sprint(i)}
+	}
+	assert.Equal(t, 4, entry.Version())
+	assert.Len(t, entry.History, 2)
+	assert.Equal(t, 3, entry.History[0].Metadata.Version)
+	assert.Equal(t, "3", entry.History[0].Metadata.Tags["v"])
+
+	values, err := entry.Revision(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", values[0].Ciphertext)
+	values, err = entry.Revision(4)
+	assert.NoError(t, err)
+	assert.Equal(t, "4", values[0].Ciphertext)
+	_, err = entry.Revision(1)
+	assert.True(t, errors.Is(err, ErrVersionNotFound))
+
+	entry.Supersede(ValueList{{Ciphertext: "5"}}, 0)
+	assert.Nil(t, entry.History)
+	assert.Equal(t, 5, entry.Version())
+}
+
+func TestEntry_SupersedeBy(t *testing.T) {
+	var entry Entry
+	entry.SupersedeBy(ValueList{{Ciphertext: "1"}}, 2, "alice")
+	created := entry.Metadata.CreatedAt
+	assert.NotNil(t, created)
+	assert.Equal(t, "alice", entry.Metadata.UpdatedBy)
+
+	entry.SupersedeBy(ValueList{{Ciphertext: "2"}}, 2, "bob")
+	assert.Equal(t, created, entry.Metadata.CreatedAt)
+	assert.Equal(t, "bob", entry.Metadata.UpdatedBy)
+	assert.Equal(t, "alice", entry.History[0].Metadata.UpdatedBy)
+
+	// Entries written before metadata existed keep an unknown creation time.
+	legacy := Entry{Values: ValueList{{Ciphertext: "1"}}}
+	legacy.SupersedeBy(ValueList{{Ciphertext: "2"}}, 2, "alice")
+	assert.Nil(t, legacy.Metadata.CreatedAt)
+	assert.NotNil(t, legacy.Metadata.UpdatedAt)
+}
+
+func TestEntryMap_HistoryLimit(t *testing.T) {
+	entries := EntryMap{}
+	assert.Equal(t, DefaultHistoryLimit, entries.HistoryLimit())
+	limit := 1
+	entries[KeyTemplateName] = Entry{HistoryLimit: &limit}
+	assert.Equal(t, 1, entries.HistoryLimit())
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := filepath.Join(dir, "secrets.yml")
+
+	bare, err := Open(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, bare.Put("k1", ValueList{}))
+	assert.NoError(t, bare.Put(KeyTemplateName, ValueList{}))
+
+	url, err := Open("file://" + filename)
+	assert.NoError(t, err)
+	names, err := url.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"k1"}, names)
+
+	_, err = Open("gopher://secrets")
+	assert.EqualError(t, err, "unsupported store scheme 'gopher' (supported: "+
+		strings.Join(GetSchemes(), ", ")+")")
+	_, err = Open("ssm://app/prod")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "use biscuit sync ssm")
+}
+
 func mustRemove(filename string) {
 	if err := os.Remove(filename); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to delete: %s\n", filename)
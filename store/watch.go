@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchable is implemented by stores kept on the local filesystem. watchDir returns the directory
+// to watch, and a function reporting whether a file in that directory belongs to the store.
+type watchable interface {
+	watchDir() (dir string, belongs func(filename string) bool)
+}
+
+func (f FileStore) watchDir() (string, func(string) bool) {
+	target := filepath.Clean(f.filename)
+	return filepath.Dir(target), func(filename string) bool {
+		return filepath.Clean(filename) == target
+	}
+}
+
+func (d DirStore) watchDir() (string, func(string) bool) {
+	dir := filepath.Clean(d.dir)
+	return dir, func(filename string) bool {
+		return filepath.Dir(filename) == dir && strings.HasSuffix(filename, dirStoreExtension)
+	}
+}
+
+// Watch calls fn each time the contents of s may have changed, until ctx is done. Stores on the
+// local filesystem are watched for file system events, which may arrive in bursts; other stores
+// are read every pollInterval and compared with the previous read. A pollInterval of zero disables
+// polling. fn is never called concurrently.
+func Watch(ctx context.Context, s Store, pollInterval time.Duration, fn func()) error {
+	w, ok := s.(watchable)
+	if !ok {
+		return poll(ctx, s, pollInterval, fn)
+	}
+	dir, belongs := w.watchDir()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op != fsnotify.Chmod && belongs(event.Name) {
+				fn()
+			}
+		case err := <-watcher.Errors:
+			return err
+		}
+	}
+}
+
+func poll(ctx context.Context, s Store, interval time.Duration, fn func()) error {
+	if interval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+	previous, _ := s.GetAll()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// Errors are assumed to be transient; the store is read again at the next tick.
+			current, err := s.GetAll()
+			if err != nil || reflect.DeepEqual(current, previous) {
+				continue
+			}
+			previous = current
+			fn()
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// watchEvents runs Watch in the background and returns a channel that receives a value each time
+// fn is called.
+func watchEvents(t *testing.T, s Store, pollInterval time.Duration) <-chan struct{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan struct{}, 100)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, s, pollInterval, func() { events <- struct{}{} })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	// Give the watcher a moment to start.
+	time.Sleep(50 * time.Millisecond)
+	return events
+}
+
+func assertEvent(t *testing.T, events <-chan struct{}) {
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change")
+	}
+	// Drain the rest of the burst.
+	for {
+		select {
+		case <-events:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestWatch_file(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestWatch")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := filepath.Join(dir, "secrets.yml")
+	store := NewFileStore(filename)
+	assert.NoError(t, store.Put("k1", ValueList{}))
+
+	events := watchEvents(t, store, 0)
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	assertEvent(t, events)
+
+	// Other files in the directory are ignored.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.yml"), []byte("{}"), 0600))
+	select {
+	case <-events:
+		t.Fatal("unexpected change")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatch_poll(t *testing.T) {
+	withFakeS3(t)
+	store, err := Open("s3://bucket/secrets.yml")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put("k1", ValueList{}))
+
+	events := watchEvents(t, store, 10*time.Millisecond)
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	assertEvent(t, events)
+}
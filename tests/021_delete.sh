@@ -0,0 +1,28 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password god -a none
+biscuit put -f store.yaml db_username oreilly -a none
+biscuit put -f store.yaml spice scary -a none
+biscuit delete -f store.yaml --dry-run 'db_*' | grep "Would delete: db_password"
+[[ "3" == "$(biscuit list -f store.yaml | wc -l)" ]]
+biscuit delete -f store.yaml 'db_*'
+[[ "spice" == "$(biscuit list -f store.yaml)" ]]
+! biscuit delete -f store.yaml _keys
+! biscuit delete -f store.yaml missing
+biscuit delete -f store.yaml spice
+[[ "0" == "$(biscuit list -f store.yaml | wc -l)" ]]
+
+# Naming the template where a secret is expected is an error for every command, not only delete.
+export BISCUIT_PASSPHRASE=hunter2
+biscuit passphrase init -f keys.yaml
+biscuit put -f keys.yaml launch_codes 0000
+biscuit describe -f keys.yaml _keys 2>&1 | grep "_keys is the key template, not a secret$"
+biscuit delete -f keys.yaml _keys 2>&1 | grep "_keys is the key template, not a secret; use --force to delete it"
+biscuit delete -f keys.yaml --force --dry-run _keys | grep "Would delete: _keys"
+cp keys.yaml before.yaml
+biscuit put -f keys.yaml _keys foo 2>&1 | grep "_keys is the key template, not a secret$"
+cmp keys.yaml before.yaml
+biscuit get -f keys.yaml _keys 2>&1 | grep "_keys is the key template, not a secret$"
+! biscuit history -f keys.yaml _keys
+biscuit put -f keys.yaml motd hello
+[[ "hello" == "$(biscuit get -f keys.yaml motd)" ]]
@@ -0,0 +1,10 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db-password god -a none
+biscuit put -f store.yaml username oreilly -a none
+[[ "god" == "$(biscuit exec -f store.yaml -- sh -c 'echo ${DB_PASSWORD}')" ]]
+[[ "oreilly" == "$(biscuit exec -f store.yaml --prefix app_ -- sh -c 'echo ${APP_USERNAME}')" ]]
+[[ "" == "$(biscuit exec -f store.yaml --only username -- sh -c 'echo ${DB_PASSWORD}')" ]]
+set +e
+biscuit exec -f store.yaml -- sh -c 'exit 7'
+[[ "7" == "$?" ]]
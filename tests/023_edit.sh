@@ -0,0 +1,9 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}","${ARN2}"
+EDITOR_SCRIPT=$(mktemp)
+printf '#!/bin/sh\nsed -i s/god/dog/ "$1"\n' > "${EDITOR_SCRIPT}"
+chmod +x "${EDITOR_SCRIPT}"
+EDITOR="${EDITOR_SCRIPT}" biscuit edit -f store.yaml password
+[[ "dog" == "$(biscuit get -f store.yaml password)" ]]
+[[ "2" == "$(grep -c key_ciphertext store.yaml)" ]]
@@ -0,0 +1,12 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}"
+biscuit put -f store.yaml username oreilly
+# Point the template (the first entry in the file) at the second region's key.
+sed -i "1,/^[a-z]/ s|${ARN1}|${ARN2}|" store.yaml
+biscuit rekey -f store.yaml --dry-run | grep "+ kms ${ARN2}"
+biscuit rekey -f store.yaml -a aesgcm256
+[[ "2" == "$(grep -c "algorithm: aesgcm256" store.yaml)" ]]
+! grep -q "key_id: ${ARN1}" store.yaml
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+[[ "oreilly" == "$(biscuit get -f store.yaml username)" ]]
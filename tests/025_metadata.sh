@@ -0,0 +1,10 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password god -a none --owner ops --tag env=prod --description "Database password"
+biscuit put -f store.yaml spice scary -a none
+biscuit list -f store.yaml --long | grep "^db_password .* ops  *env=prod  *Database password"
+biscuit put -f store.yaml db_password dog -a none --tag env=
+biscuit list -f store.yaml --long | grep "^db_password .* ops  *-  *Database password"
+grep -q "created_at:" store.yaml
+[[ "dog" == "$(biscuit get -f store.yaml db_password)" ]]
+[[ "2" == "$(biscuit list -f store.yaml | wc -l)" ]]
@@ -0,0 +1,24 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password one -a none
+biscuit put -f store.yaml db_password two -a none
+biscuit put -f store.yaml db_password three -a none
+biscuit history -f store.yaml db_password | grep "^3 (current)"
+[[ "one" == "$(biscuit get -f store.yaml db_password --at-version 1)" ]]
+! biscuit get -f store.yaml db_password --at-version 7
+biscuit rollback -f store.yaml db_password --to 2 | grep "as version 4"
+[[ "two" == "$(biscuit get -f store.yaml db_password)" ]]
+[[ "5" == "$(biscuit history -f store.yaml db_password | wc -l)" ]]
+biscuit put -f store.yaml db_password four -a none --history-limit 1
+grep "history_limit: 1" store.yaml
+[[ "3" == "$(biscuit history -f store.yaml db_password | wc -l)" ]]
+! biscuit put -f store.yaml db_password five -a none --history-limit=-1
+
+# Rotating data keys also rotates the keys of previous versions.
+biscuit put -f kms.yaml api_key one --key-id "${ARN1}"
+biscuit put -f kms.yaml api_key two
+before="$(sed -n '/^  history:/,$ s/.*key_ciphertext: //p' kms.yaml)"
+biscuit rotate -f kms.yaml | grep "^api_key (version 1): "
+after="$(sed -n '/^  history:/,$ s/.*key_ciphertext: //p' kms.yaml)"
+[[ -n "${before}" && "${before}" != "${after}" ]]
+[[ "one" == "$(biscuit get -f kms.yaml api_key --at-version 1)" ]]
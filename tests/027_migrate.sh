@@ -0,0 +1,13 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password god -a none
+biscuit put -f store.yaml spice/level scary -a none
+biscuit migrate -f store.yaml --to dir://secrets | grep "Copied 2 secrets"
+[[ -f secrets/_keys.yml && -f secrets/db_password.yml && -f secrets/spice%2Flevel.yml ]]
+[[ "scary" == "$(biscuit get -f dir://secrets spice/level)" ]]
+! biscuit migrate -f store.yaml --to dir://secrets
+biscuit put -f dir://secrets db_password dog -a none
+[[ "dog" == "$(biscuit get -f dir://secrets db_password)" ]]
+[[ "god" == "$(biscuit get -f store.yaml db_password)" ]]
+biscuit delete -f dir://secrets db_password
+[[ ! -f secrets/db_password.yml ]]
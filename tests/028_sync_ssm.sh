@@ -0,0 +1,18 @@
+#!/bin/bash -x
+set -e
+SSM_PATH="/biscuit-test/${RANDOM}${RANDOM}"
+biscuit put -f store.yaml db_password god -a none
+biscuit put -f store.yaml api/key k1 -a none
+biscuit sync ssm push -f store.yaml --path "${SSM_PATH}" --dry-run | grep "2 to add"
+biscuit sync ssm push -f store.yaml --path "${SSM_PATH}"
+biscuit sync ssm push -f store.yaml --path "${SSM_PATH}" | grep "2 unchanged"
+biscuit delete -f store.yaml db_password
+biscuit sync ssm push -f store.yaml --path "${SSM_PATH}" --prune | grep -- "- ${SSM_PATH}/db_password"
+biscuit put -f pulled.yaml placeholder x -a none
+biscuit sync ssm pull -f pulled.yaml --path "${SSM_PATH}" | grep "+ api/key"
+[[ "k1" == "$(biscuit get -f pulled.yaml api/key)" ]]
+# Values too large for the parameter tier are rejected before anything is written.
+head -c 5000 /dev/zero | tr '\0' x > big.txt
+biscuit put -f big.yaml big --from-file big.txt -a none
+biscuit sync ssm push -f big.yaml --path "${SSM_PATH}" 2>&1 | grep "use --tier Advanced"
+biscuit sync ssm push -f big.yaml --path "${SSM_PATH}" --tier Advanced --dry-run | grep "1 to add"
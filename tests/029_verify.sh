@@ -0,0 +1,10 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password god --key-id "${ARN1},${ARN2}"
+biscuit put -f store.yaml spice scary -a none
+biscuit verify -f store.yaml | grep "All 3 values decrypted successfully."
+# Break the copy of the key encrypted in the second region.
+sed -i "/^db_password:/,\$ { /key_id: ${ARN2}/,/key_ciphertext/ s|key_ciphertext: .*|key_ciphertext: AAAA| }" store.yaml
+! biscuit verify -f store.yaml
+biscuit verify -f store.yaml --failed-only 2>&1 | grep "${REGION2}.*FAILED"
+[[ "god" == "$(biscuit get -f store.yaml db_password)" ]]
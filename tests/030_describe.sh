@@ -0,0 +1,17 @@
+#!/bin/bash -x
+set -e
+cat > store.yaml <<EOT
+_keys:
+- key_id: ${ARN1}
+  key_manager: kms
+  algorithm: secretbox
+- key_id: ${ARN2}
+  key_manager: kms
+  algorithm: secretbox
+EOT
+biscuit put -f store.yaml db_password god
+biscuit put -f store.yaml spice scary --key-id "${ARN1}"
+biscuit describe -f store.yaml db_password | grep "db_password.*${REGION2}"
+! biscuit describe -f store.yaml db_password | grep WARNING
+biscuit describe -f store.yaml | grep "WARNING: spice is not encrypted in ${REGION2}"
+biscuit describe -f store.yaml --format json spice | grep "\"missing_regions\""
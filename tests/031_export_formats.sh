@@ -0,0 +1,21 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password "it's \$HOME" -a none
+biscuit put -f store.yaml spice/level scary --key-id "${ARN1}"
+biscuit put -f store.yaml admin_password hunter2 -a none
+biscuit export -f store.yaml --format json | grep '"spice/level": "scary"'
+biscuit export -f store.yaml --format dotenv | grep "^SPICE_LEVEL='scary'$"
+biscuit export -f store.yaml --format dotenv | grep -F 'DB_PASSWORD="it'"'"'s \$HOME"'
+(
+  eval "$(biscuit export -f store.yaml --format shell --only 'db_*')"
+  [[ "it's \$HOME" == "${DB_PASSWORD}" ]]
+  [[ -z "${ADMIN_PASSWORD}" ]]
+)
+biscuit export -f store.yaml --format tfvars | grep '^spice_level = "scary"$'
+! biscuit export -f store.yaml --format k8s-secret
+T=$(mktemp)
+biscuit export -f store.yaml --format k8s-secret --exclude 'spice/*,admin_*' --k8s-namespace prod > "${T}"
+grep "kind: Secret" "${T}"
+grep "namespace: prod" "${T}"
+grep "db_password: $(echo -n "it's \$HOME" | base64)" "${T}"
+! grep admin_password "${T}"
@@ -0,0 +1,30 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml existing old --key-id "${ARN1},${ARN2}"
+cat > secrets.env <<'EOT'
+# Database credentials
+export DB_USER=admin
+DB_PASSWORD="it's \"quoted\""
+existing='new'
+EOT
+! biscuit import -f store.yaml --format dotenv secrets.env
+biscuit import -f store.yaml --format dotenv --dry-run --overwrite secrets.env | grep "2 to add, 1 to overwrite"
+[[ "" == "$(biscuit list -f store.yaml | grep DB_)" ]]
+biscuit import -f store.yaml --format dotenv --skip-existing secrets.env
+[[ "it's \"quoted\"" == "$(biscuit get -f store.yaml DB_PASSWORD)" ]]
+[[ "old" == "$(biscuit get -f store.yaml existing)" ]]
+biscuit import -f store.yaml --format dotenv --overwrite secrets.env
+[[ "new" == "$(biscuit get -f store.yaml existing)" ]]
+[[ "old" == "$(biscuit get -f store.yaml existing --at-version 1)" ]]
+biscuit export -f store.yaml --format k8s-secret --only 'DB_*' | sed 's/DB_/K8S_/' > secret.yaml
+biscuit import -f store.yaml --format k8s-secret secret.yaml
+[[ "admin" == "$(biscuit get -f store.yaml K8S_USER)" ]]
+echo '{"port": 5432}' | biscuit import -f store.yaml --format json
+[[ "5432" == "$(biscuit get -f store.yaml port)" ]]
+LONG="$(head -c 100000 /dev/zero | tr '\0' x)"
+echo "LONG_VALUE=${LONG}" | biscuit import -f store.yaml --format dotenv
+[[ "${LONG}" == "$(biscuit get -f store.yaml LONG_VALUE)" ]]
+# Text after a quoted value is an error rather than being dropped; comments are allowed.
+! echo 'JUNK="x" junk' | biscuit import -f store.yaml --format dotenv
+echo "COMMENTED='x' # a comment" | biscuit import -f store.yaml --format dotenv
+[[ "x" == "$(biscuit get -f store.yaml COMMENTED)" ]]
@@ -0,0 +1,16 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password "god's" --key-id "${ARN1},${ARN2}"
+biscuit put -f store.yaml unused scary -a none
+cat > config.tmpl <<'EOT'
+[database]
+password = {{ secret "db_password" | quote }}
+encoded = {{ secret "db_password" | base64 }}
+EOT
+biscuit render -f store.yaml config.tmpl -o config.ini
+[[ "600" == "$(stat -c %a config.ini)" ]]
+grep '^password = "god'"'"'s"$' config.ini
+grep "^encoded = $(echo -n "god's" | base64)$" config.ini
+echo '{{ secret "missing" }}' > bad.tmpl
+! biscuit render -f store.yaml bad.tmpl -o config.ini
+grep "^password" config.ini
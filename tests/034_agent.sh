@@ -0,0 +1,26 @@
+#!/bin/bash -x
+set -e
+export BISCUIT_AGENT_SOCKET="${HOME}/agent/agent.sock"
+biscuit put -f store.yaml db_password god --key-id "${ARN1},${ARN2}"
+biscuit agent -f store.yaml --ttl 1m &
+AGENT=$!
+trap "kill ${AGENT}" EXIT
+for i in $(seq 50); do [[ -S "${BISCUIT_AGENT_SOCKET}" ]] && break; sleep 0.1; done
+[[ "600" == "$(stat -c %a "${BISCUIT_AGENT_SOCKET}")" ]]
+[[ "god" == "$(biscuit get -f store.yaml --agent db_password)" ]]
+# A second agent cannot take over the socket.
+! biscuit agent -f store.yaml
+# Nor can an agent listen in a directory that belongs to another user.
+if [[ 0 == "$(id -u)" ]]; then
+  mkdir -m 755 other && chown nobody other
+  ! biscuit agent -f store.yaml --socket other/agent.sock
+fi
+# Changes to the store are picked up without restarting the agent.
+biscuit put -f store.yaml db_password dog --key-id "${ARN1}"
+sleep 1
+[[ "dog" == "$(biscuit get -f store.yaml --agent db_password)" ]]
+# Errors from the agent exit with the same status as without it.
+set +e
+biscuit get -f store.yaml --agent missing
+[[ 3 == $? ]]
+set -e
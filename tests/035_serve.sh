@@ -0,0 +1,16 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db/password god --key-id "${ARN1},${ARN2}"
+biscuit put -f store.yaml admin scary -a none
+openssl rand -hex 16 > token
+! biscuit serve -f store.yaml --listen 127.0.0.1:8099
+biscuit serve -f store.yaml --listen 127.0.0.1:8099 --token-file token --allow 'db/*' &
+SERVER=$!
+trap "kill ${SERVER}" EXIT
+for i in $(seq 50); do wget -qO- http://127.0.0.1:8099/healthz && break; sleep 0.1; done
+wget -qO- http://127.0.0.1:8099/readyz
+! wget -qO- http://127.0.0.1:8099/v1/secrets/db/password
+AUTH="Authorization: Bearer $(cat token)"
+wget -qO- --header "${AUTH}" http://127.0.0.1:8099/v1/secrets | grep '"names":\["db/password"\]'
+wget -qO- --header "${AUTH}" http://127.0.0.1:8099/v1/secrets/db/password | grep '"value":"god"'
+! wget -qO- --header "${AUTH}" http://127.0.0.1:8099/v1/secrets/admin
@@ -0,0 +1,19 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password god --key-id "${ARN1},${ARN2}"
+biscuit put -f store.yaml unrelated scary -a none
+echo 'password={{ secret "db_password" }}' > config.tmpl
+biscuit watch -f store.yaml --template config.tmpl:config.ini --debounce 100ms \
+  --command 'echo reloaded >> reloads.log' &
+WATCHER=$!
+trap "kill ${WATCHER}" EXIT
+for i in $(seq 50); do [[ -f reloads.log ]] && break; sleep 0.1; done
+[[ "password=god" == "$(cat config.ini)" ]]
+[[ "600" == "$(stat -c %a config.ini)" ]]
+# Changing a secret that the template does not use leaves the output alone.
+biscuit put -f store.yaml unrelated boo -a none
+sleep 1
+[[ "1" == "$(wc -l < reloads.log)" ]]
+biscuit put -f store.yaml db_password dog --key-id "${ARN1}"
+for i in $(seq 50); do [[ "2" == "$(wc -l < reloads.log)" ]] && break; sleep 0.1; done
+[[ "password=dog" == "$(cat config.ini)" ]]
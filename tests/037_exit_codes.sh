@@ -0,0 +1,27 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}","${ARN2}"
+biscuit put -f store.yaml username oreilly
+
+status=0
+biscuit get -f store.yaml missing || status=$?
+[[ 3 == "${status}" ]]
+
+cp store.yaml corrupt.yaml
+sed -i "s@${ARN1_REGION}@xxx@g; s@${ARN2_REGION}@xxx@g" corrupt.yaml
+status=0
+biscuit get -f corrupt.yaml password 2>&1 | grep "could not decrypt password with any of 2 keys" || status=1
+[[ 0 == "${status}" ]]
+status=0
+biscuit get -f corrupt.yaml password || status=$?
+[[ 5 == "${status}" ]]
+# export writes the secrets that it can, and reports the others.
+status=0
+biscuit export -f corrupt.yaml --format dotenv > exported.env || status=$?
+[[ 5 == "${status}" ]]
+grep "^USERNAME=" exported.env
+
+biscuit delete -f store.yaml _keys --force
+status=0
+biscuit put -f store.yaml launch_codes 0000 || status=$?
+[[ 4 == "${status}" ]]
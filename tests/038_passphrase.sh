@@ -0,0 +1,16 @@
+#!/bin/bash -x
+set -e
+export BISCUIT_PASSPHRASE=hunter2
+biscuit passphrase init -f store.yaml
+biscuit passphrase init -f store.yaml
+[[ 1 == "$(grep -c 'key_manager: passphrase' store.yaml)" ]]
+biscuit put -f store.yaml launch_codes 0000
+[[ "0000" == "$(biscuit get -f store.yaml launch_codes)" ]]
+! BISCUIT_PASSPHRASE=hunter3 biscuit put -f store.yaml launch_codes 1111
+
+# The salt is generated once, when the store is created, and reused by later writes.
+biscuit put -f new.yaml -p passphrase --key-id scrypt launch_codes 0000
+biscuit put -f new.yaml -p passphrase --key-id scrypt motd hello
+[[ 1 == "$(sed -n 's/.*key_id: //p' new.yaml | sort -u | wc -l)" ]]
+! BISCUIT_PASSPHRASE=hunter3 biscuit put -f new.yaml -p passphrase --key-id scrypt motd bye
+[[ "hello" == "$(biscuit get -f new.yaml motd)" ]]
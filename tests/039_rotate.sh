@@ -0,0 +1,26 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml db_password hunter2 --key-id "${ARN1}"
+biscuit put -f store.yaml api_key sekrit
+biscuit put -f store.yaml plain_value public -a none
+
+# Freshly generated data keys are younger than the cutoff.
+biscuit rotate -f store.yaml --older-than 90d | grep "^Rotated 0 value"
+biscuit rotate -f store.yaml --older-than 2w | grep "^Rotated 0 value"
+
+# A dry run lists the values without changing the file; unencrypted values are never rotated.
+cp store.yaml before.yaml
+biscuit rotate -f store.yaml --older-than 0s --dry-run | tee dry-run.txt
+grep "^Dry run: 2 values would be rotated." dry-run.txt
+grep "^db_password: " dry-run.txt
+grep "^api_key: " dry-run.txt
+! grep "^plain_value: " dry-run.txt
+cmp store.yaml before.yaml
+
+biscuit rotate -f store.yaml | grep "^Rotated 2 values."
+! cmp store.yaml before.yaml
+[[ "hunter2" == "$(biscuit get -f store.yaml db_password)" ]]
+[[ "sekrit" == "$(biscuit get -f store.yaml api_key)" ]]
+[[ "public" == "$(biscuit get -f store.yaml plain_value)" ]]
+
+! biscuit rotate -f store.yaml --older-than 3x